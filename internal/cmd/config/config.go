@@ -30,6 +30,9 @@ var (
 # Data directory for wallet files
 datadir = "~/.blindbit-wallet"
 
+# Wallet used when --wallet is not given, see 'wallet list'
+default_wallet = "default"
+
 # Network configuration (mainnet, testnet, signet, regtest)
 network = "mainnet"
 
@@ -65,6 +68,7 @@ scan_pass = ""
 			fmt.Println("Current Configuration:")
 			fmt.Println("---------------------")
 			fmt.Printf("Data Directory: %s\n", viper.GetString("datadir"))
+			fmt.Printf("Wallet: %s (%s)\n", viper.GetString("wallet"), viper.GetString("walletdir"))
 			fmt.Printf("Scan Host: %s\n", viper.GetString("scan_host"))
 			fmt.Printf("Scan Port: %d\n", viper.GetInt("scan_port"))
 			fmt.Printf("Scan User: %s\n", viper.GetString("scan_user"))
@@ -8,7 +8,9 @@ import (
 	configcmd "github.com/setavenger/blindbit-wallet-cli/internal/cmd/config"
 	walletcmd "github.com/setavenger/blindbit-wallet-cli/internal/cmd/wallet"
 	"github.com/setavenger/blindbit-wallet-cli/internal/config"
+	"github.com/setavenger/blindbit-wallet-cli/pkg/storage"
 	"github.com/setavenger/blindbit-wallet-cli/pkg/utils"
+	"github.com/setavenger/blindbit-wallet-cli/pkg/wallet"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -48,7 +50,7 @@ var RootCmd = &cobra.Command{
 			return fmt.Errorf("failed to load config: %w", err)
 		}
 
-		return nil
+		return resolveWallet()
 	},
 }
 
@@ -68,9 +70,13 @@ func init() {
 	// Set default datadir
 	RootCmd.PersistentFlags().String("datadir", defaultDataDir, "datadir default ($HOME/.blindbit-wallet)")
 
+	// Select one of the wallets in the datadir, default_wallet from the config is used if not set
+	RootCmd.PersistentFlags().String("wallet", "", "name of the wallet to use (default is default_wallet from the config)")
+
 	// Bind flags to viper
 	viper.BindPFlag("config", RootCmd.PersistentFlags().Lookup("config"))
 	viper.BindPFlag("datadir", RootCmd.PersistentFlags().Lookup("datadir"))
+	viper.BindPFlag("wallet", RootCmd.PersistentFlags().Lookup("wallet"))
 
 	RootCmd.AddCommand(configcmd.NewCommand())
 	RootCmd.AddCommand(walletcmd.NewCommand())
@@ -83,6 +89,18 @@ func initConfig() {
 	viper.SetDefault("scan_user", "")
 	viper.SetDefault("scan_pass", "")
 
+	// Wallet used when --wallet is not given
+	viper.SetDefault("default_wallet", storage.DefaultWalletName)
+
+	// Number of previous wallet file versions kept in <datadir>/backups
+	viper.SetDefault("max_backups", storage.DefaultMaxBackups)
+
+	// How long to wait for another invocation holding the wallet lock
+	viper.SetDefault("lock_timeout", "30s")
+
+	// Coin selection strategy used when send is not given --coin-selection
+	viper.SetDefault("coin_selection", string(wallet.DefaultCoinSelectionStrategy))
+
 	// Tor configuration defaults
 	viper.SetDefault("use_tor", false)
 	viper.SetDefault("tor_host", "localhost")
@@ -111,3 +129,19 @@ func loadConfig() error {
 
 	return nil
 }
+
+// resolveWallet determines the active wallet, --wallet first and default_wallet from the config otherwise.
+// The config and wallet command trees both read the result from viper, see walletcmd.SelectWallet.
+func resolveWallet() error {
+	name := viper.GetString("wallet")
+	if name == "" {
+		name = viper.GetString("default_wallet")
+	}
+
+	if err := walletcmd.SelectWallet(name); err != nil {
+		return err
+	}
+
+	cfg.Wallet = viper.GetString("wallet")
+	return nil
+}
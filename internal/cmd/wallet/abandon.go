@@ -0,0 +1,37 @@
+package wallet
+
+import (
+	"fmt"
+
+	"github.com/setavenger/blindbit-wallet-cli/pkg/wallet"
+	"github.com/spf13/cobra"
+)
+
+func NewAbandonCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "abandon <txid>",
+		Short: "Forget a pending transaction that was never broadcast",
+		Long: `Drop a pending transaction created by 'wallet send' and make its inputs spendable again.
+Only use this for transactions that were not broadcast, otherwise the coins get spent twice.
+Pending transactions are listed in the SPENT BY column of 'wallet utxos'.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			handle, err := loadWallet(wallet.LockExclusive)
+			if err != nil {
+				return err
+			}
+			defer handle.close()
+
+			if err := handle.Data.AbandonPending(args[0]); err != nil {
+				return err
+			}
+
+			if err := handle.save(); err != nil {
+				return fmt.Errorf("failed to save wallet data: %w", err)
+			}
+
+			fmt.Println("Abandoned transaction", args[0])
+			return nil
+		},
+	}
+}
@@ -2,6 +2,7 @@ package wallet
 
 import (
 	"fmt"
+	"strconv"
 
 	"github.com/setavenger/blindbit-wallet-cli/pkg/wallet"
 	"github.com/setavenger/go-bip352"
@@ -13,14 +14,25 @@ var addressCmd = &cobra.Command{
 	Use:   "address",
 	Short: "Generate a silent payment address",
 	Long: `Generate a silent payment address for receiving payments.
-The address can be labeled (M=1,2,3...) for different purposes.
+The address can be labeled (M=1,2,3...) for different purposes, see 'wallet label' to give labels names.
 Note: Label 0 is reserved for change addresses.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		datadir := viper.GetString("datadir")
-		w, err := wallet.Load(datadir)
+		// Get label number from flag
+		labelNum, _ := cmd.Flags().GetUint32("label")
+		showChange, _ := cmd.Flags().GetBool("change")
+
+		// labels handed out are recorded so 'wallet label create' does not reuse them
+		lockMode := wallet.LockShared
+		if labelNum > 0 && !showChange {
+			lockMode = wallet.LockExclusive
+		}
+		handle, err := loadWallet(lockMode)
 		if err != nil {
-			return fmt.Errorf("failed to load wallet: %w", err)
+			return err
 		}
+		defer handle.close()
+		w := &handle.Data.Wallet
+		storedNetwork := w.Network
 
 		// Get network from flag if specified, otherwise use config file value
 		if cmd.Flags().Changed("network") {
@@ -33,10 +45,6 @@ Note: Label 0 is reserved for change addresses.`,
 			}
 		}
 
-		// Get label number from flag
-		labelNum, _ := cmd.Flags().GetUint32("label")
-		showChange, _ := cmd.Flags().GetBool("change")
-
 		var address string
 		if showChange {
 			var label bip352.Label
@@ -54,6 +62,13 @@ Note: Label 0 is reserved for change addresses.`,
 				return fmt.Errorf("failed to compute label: %w", err)
 			}
 			address = label.Address
+
+			if _, err := handle.Data.FindLabel(strconv.FormatUint(uint64(labelNum), 10)); err != nil && w.Network == storedNetwork {
+				handle.Data.Labels = append(handle.Data.Labels, wallet.Label{Label: label})
+				if err := handle.save(); err != nil {
+					return fmt.Errorf("failed to save wallet data: %w", err)
+				}
+			}
 		} else {
 
 			// Create base address (no label)
@@ -71,8 +86,12 @@ Note: Label 0 is reserved for change addresses.`,
 
 		fmt.Println("Silent Payment Address:")
 		fmt.Println(address)
-		if labelNum > 0 {
-			fmt.Printf("Label: M=%d\n", labelNum)
+		if labelNum > 0 && !showChange {
+			if name := handle.Data.LabelName(labelNum); name != fmt.Sprintf("M=%d", labelNum) {
+				fmt.Printf("Label: %s (M=%d)\n", name, labelNum)
+			} else {
+				fmt.Printf("Label: M=%d\n", labelNum)
+			}
 		}
 
 		return nil
@@ -0,0 +1,212 @@
+package wallet
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/setavenger/blindbit-wallet-cli/pkg/storage"
+	"github.com/setavenger/blindbit-wallet-cli/pkg/wallet"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// archivedConfigKeys are the settings stored in a full-state backup
+var archivedConfigKeys = []string{
+	"network",
+	"scan_host",
+	"scan_port",
+	"scan_user",
+	"scan_pass",
+	"use_tor",
+	"tor_host",
+	"tor_port",
+	"tor_control",
+}
+
+func NewBackupCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "backup <file>",
+		Short: "Write an encrypted backup of the full wallet state",
+		Long: `Write the full wallet state (keys, UTXOs and their states, labels, scan height) and the
+scan daemon and Tor settings into a single archive encrypted with a backup passphrase.
+Unlike a mnemonic restore, 'wallet restore' recreates the wallet from it without a rescan.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := args[0]
+			if _, err := os.Stat(path); err == nil {
+				return fmt.Errorf("%s already exists", path)
+			}
+
+			handle, err := loadWallet(wallet.LockShared)
+			if err != nil {
+				return err
+			}
+			defer handle.close()
+
+			config := make(map[string]any, len(archivedConfigKeys))
+			for _, key := range archivedConfigKeys {
+				if viper.IsSet(key) {
+					config[key] = viper.Get(key)
+				}
+			}
+
+			archive, err := storage.NewArchive(viper.GetString("wallet"), handle.Data, config)
+			if err != nil {
+				return err
+			}
+
+			passphrase, err := readNewSecret("backup passphrase")
+			if err != nil {
+				return err
+			}
+
+			if err := storage.WriteArchive(path, archive, passphrase); err != nil {
+				return fmt.Errorf("failed to write backup: %w", err)
+			}
+
+			fmt.Println("Backup written to", path)
+			fmt.Println("The archive includes the spend secret (if any) and the scan daemon credentials, keep it safe.")
+			return nil
+		},
+	}
+}
+
+func NewRestoreCmd() *cobra.Command {
+	var (
+		name          string
+		restoreConfig bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "restore <file>",
+		Short: "Restore a wallet from a backup written by 'wallet backup'",
+		Long: `Validate a backup archive and recreate the wallet from it, including UTXOs, labels and scan height.
+The wallet is restored as the active wallet (--wallet) unless --name is given.
+With --restore-config the archived scan daemon and Tor settings are written to blindbit.toml.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if name != "" {
+				if err := SelectWallet(name); err != nil {
+					return err
+				}
+			}
+
+			passphrase, err := promptSecret("Enter backup passphrase: ")
+			if err != nil {
+				return err
+			}
+
+			archive, err := storage.ReadArchive(args[0], passphrase)
+			if err != nil {
+				return err
+			}
+
+			data, err := archive.WalletData()
+			if err != nil {
+				return fmt.Errorf("failed to decode archived wallet: %w", err)
+			}
+			if err := data.Validate(); err != nil {
+				return fmt.Errorf("backup failed validation: %w", err)
+			}
+
+			if archivedNetwork, ok := archive.Config["network"].(string); ok && archivedNetwork != string(data.Wallet.Network) {
+				return fmt.Errorf("backup is inconsistent: wallet network %s, config network %s", data.Wallet.Network, archivedNetwork)
+			}
+
+			fmt.Printf("Backup of wallet %q from %s\n", archive.WalletName, archive.CreatedAt.Local().Format("2006-01-02 15:04:05"))
+			fmt.Println("Network:", data.Wallet.Network)
+			fmt.Println("Watch-only:", data.Wallet.IsWatchOnly())
+			fmt.Println("UTXOs:", len(data.UTXOs))
+			fmt.Println("Labels:", len(data.Labels))
+			fmt.Println("Last height:", data.LastHeight)
+
+			if network := viper.GetString("network"); network != "" && network != string(data.Wallet.Network) && !restoreConfig {
+				fmt.Printf("Warning: the configured network is %s but the backup is a %s wallet.\n", network, data.Wallet.Network)
+			}
+
+			store, err := openStorage()
+			if err != nil {
+				return err
+			}
+
+			if store.WalletExists() {
+				fmt.Println("Warning: A wallet already exists at:", store.WalletPath())
+				fmt.Println("Restoring will overwrite it (the current file is kept in the wallet backups).")
+			}
+			if !confirm(fmt.Sprintf("Restore into wallet %q?", viper.GetString("wallet"))) {
+				fmt.Println("Operation cancelled.")
+				return nil
+			}
+
+			password, err := readNewPassword()
+			if err != nil {
+				return err
+			}
+
+			lock, err := lockWallet(wallet.LockExclusive)
+			if err != nil {
+				return err
+			}
+			defer lock.Release()
+
+			if err := store.SaveWallet(data, password); err != nil {
+				return fmt.Errorf("failed to save wallet: %w", err)
+			}
+
+			if restoreConfig && len(archive.Config) > 0 {
+				if err := writeArchivedConfig(archive.Config); err != nil {
+					return err
+				}
+			}
+
+			fmt.Println("Wallet restored successfully!")
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&name, "name", "", "Restore into the wallet with this name instead of the active wallet")
+	cmd.Flags().BoolVar(&restoreConfig, "restore-config", false, "Write the archived scan daemon and Tor settings to blindbit.toml")
+
+	return cmd
+}
+
+// writeArchivedConfig merges the archived settings into the config file, the old file is kept as .bak
+func writeArchivedConfig(config map[string]any) error {
+	path := viper.ConfigFileUsed()
+
+	fileConfig := viper.New()
+	fileConfig.SetConfigFile(path)
+	if raw, err := os.ReadFile(path); err == nil {
+		if err := fileConfig.ReadInConfig(); err != nil {
+			return fmt.Errorf("failed to read config file: %w", err)
+		}
+		if err := os.WriteFile(path+".bak", raw, 0600); err != nil {
+			return fmt.Errorf("failed to back up config file: %w", err)
+		}
+	}
+
+	keys := make([]string, 0, len(config))
+	for key := range config {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		// JSON decodes all numbers as float64, the config only has integer settings (ports)
+		if f, ok := config[key].(float64); ok && f == float64(int64(f)) {
+			config[key] = int64(f)
+		}
+		fileConfig.Set(key, config[key])
+		if key != "scan_pass" {
+			fmt.Printf("  %s = %v\n", key, config[key])
+		}
+	}
+
+	if err := fileConfig.WriteConfigAs(path); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+
+	fmt.Println("Settings written to", path)
+	return nil
+}
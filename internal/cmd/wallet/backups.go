@@ -0,0 +1,90 @@
+package wallet
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/setavenger/blindbit-wallet-cli/pkg/wallet"
+	"github.com/spf13/cobra"
+)
+
+var (
+	backupsCmd = &cobra.Command{
+		Use:   "backups",
+		Short: "Manage automatic wallet file backups",
+		Long: `Every time the wallet file is written the previous version is kept in <datadir>/backups.
+The number of kept versions is set with max_backups in blindbit.toml.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+
+	backupsListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List available wallet file backups",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := openStorage()
+			if err != nil {
+				return err
+			}
+
+			backups, err := store.ListBackups()
+			if err != nil {
+				return err
+			}
+			if len(backups) == 0 {
+				fmt.Println("No backups found.")
+				return nil
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			defer w.Flush()
+
+			fmt.Fprintln(w, "NAME\tTIME\tTAG\tSIZE")
+			for _, b := range backups {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%d\n", b.Name, b.Time.Local().Format("2006-01-02 15:04:05"), b.Tag, b.Size)
+			}
+			return nil
+		},
+	}
+
+	backupsRestoreCmd = &cobra.Command{
+		Use:   "restore <name>",
+		Short: "Restore the wallet file from a backup",
+		Long: `Replace the current wallet file with one of the backups from 'wallet backups list'.
+The current file is backed up first, so the restore can be undone.
+While the wallet has a password its backups are encrypted with it.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := openStorage()
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("This will replace %s with backup %s.\n", store.WalletPath(), args[0])
+			if !confirm("Do you want to continue?") {
+				fmt.Println("Operation cancelled.")
+				return nil
+			}
+
+			lock, err := lockWallet(wallet.LockExclusive)
+			if err != nil {
+				return err
+			}
+			defer lock.Release()
+
+			if err := store.RestoreBackup(args[0]); err != nil {
+				return fmt.Errorf("failed to restore backup: %w", err)
+			}
+
+			fmt.Println("Backup restored successfully!")
+			return nil
+		},
+	}
+)
+
+func init() {
+	backupsCmd.AddCommand(backupsListCmd)
+	backupsCmd.AddCommand(backupsRestoreCmd)
+}
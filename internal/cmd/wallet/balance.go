@@ -0,0 +1,74 @@
+package wallet
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/setavenger/blindbit-wallet-cli/pkg/wallet"
+	"github.com/spf13/cobra"
+)
+
+func NewBalanceCmd() *cobra.Command {
+	var feeRate feeRateFlag
+
+	cmd := &cobra.Command{
+		Use:   "balance",
+		Short: "Show the wallet balance",
+		Long: `Show the balance from the coins of the last sync: confirmed (spendable), unconfirmed incoming,
+spent by pending transactions and a breakdown per label.
+With --fee-rate the coins that cost at least their value to spend at that rate are listed as uneconomical.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			handle, err := loadWallet(wallet.LockShared)
+			if err != nil {
+				return err
+			}
+			defer handle.close()
+
+			balance := handle.Data.Balance(feeRate.FeeRate)
+
+			for _, line := range []struct {
+				name   string
+				amount uint64
+			}{
+				{"Confirmed", balance.Confirmed},
+				{"  of which frozen", balance.Frozen},
+				{"Unconfirmed incoming", balance.Unconfirmed},
+				{"Pending outgoing", balance.PendingOut},
+				{"Pending change", balance.PendingChange},
+				{"Total", balance.Total()},
+			} {
+				fmt.Printf("%-22s %14d sats\n", line.name+":", line.amount)
+			}
+
+			if len(balance.ByLabel) > 0 {
+				fmt.Println()
+				fmt.Println("By label (confirmed and unconfirmed):")
+				labels := make([]uint32, 0, len(balance.ByLabel))
+				for m := range balance.ByLabel {
+					labels = append(labels, m)
+				}
+				slices.Sort(labels)
+
+				for _, m := range labels {
+					fmt.Printf("  %-20s %14d sats\n", handle.Data.LabelName(m)+":", balance.ByLabel[m])
+				}
+			}
+
+			if !feeRate.IsZero() {
+				fmt.Println()
+				fmt.Printf("Uneconomical at %s (input fee %d sats): %d coins, %d sats\n",
+					feeRate.FeeRate, wallet.InputFee(feeRate.FeeRate), balance.UneconomicalCount, balance.Uneconomical)
+			}
+
+			if handle.Data.LastHeight > 0 {
+				fmt.Println()
+				fmt.Println("As of the last sync at height", handle.Data.LastHeight)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().Var(&feeRate, "fee-rate", "Count coins that are uneconomical to spend at this fee rate (sat/vB)")
+
+	return cmd
+}
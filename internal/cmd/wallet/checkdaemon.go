@@ -0,0 +1,49 @@
+package wallet
+
+import (
+	"fmt"
+
+	"github.com/setavenger/blindbit-wallet-cli/pkg/wallet"
+	"github.com/spf13/cobra"
+)
+
+func NewCheckDaemonCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "check-daemon",
+		Short: "Check that blindbit-scan scans for this wallet",
+		Long: `Compare the address the blindbit-scan daemon derives from its configured keys with the wallet address.
+This is the same check 'wallet sync' runs before trusting the daemon's UTXOs.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			handle, err := loadWallet(wallet.LockShared)
+			if err != nil {
+				return err
+			}
+			defer handle.close()
+
+			client, closeClient, err := newScanClient()
+			if err != nil {
+				return err
+			}
+			defer closeClient()
+
+			if err := checkDaemonKeys(client, handle.Data.Wallet); err != nil {
+				return err
+			}
+
+			height, err := client.GetCurrentHeight()
+			if err != nil {
+				return fmt.Errorf("failed to get current height: %w", err)
+			}
+
+			address, err := handle.Data.Wallet.Address()
+			if err != nil {
+				return fmt.Errorf("failed to create address: %w", err)
+			}
+
+			fmt.Println("The scan daemon is scanning for this wallet.")
+			fmt.Println("Address:", address)
+			fmt.Println("Daemon height:", height)
+			return nil
+		},
+	}
+}
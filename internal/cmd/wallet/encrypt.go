@@ -0,0 +1,178 @@
+package wallet
+
+import (
+	"fmt"
+
+	"github.com/setavenger/blindbit-wallet-cli/pkg/storage"
+	"github.com/setavenger/blindbit-wallet-cli/pkg/wallet"
+	"github.com/spf13/cobra"
+)
+
+var (
+	encryptCmd = &cobra.Command{
+		Use:   "encrypt",
+		Short: "Encrypt a plaintext wallet file",
+		Long: `Encrypt an existing plaintext wallet.json with a password. All further commands will ask for that password.
+The backups in <datadir>/backups are encrypted with the same password.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := openStorage()
+			if err != nil {
+				return err
+			}
+
+			encrypted, err := store.IsEncrypted()
+			if err != nil {
+				return err
+			}
+			if encrypted {
+				return fmt.Errorf("wallet is already encrypted, use 'wallet change-password' instead")
+			}
+
+			password, err := readNewPassword()
+			if err != nil {
+				return err
+			}
+
+			lock, err := lockWallet(wallet.LockExclusive)
+			if err != nil {
+				return err
+			}
+			defer lock.Release()
+
+			data, err := store.LoadWallet("")
+			if err != nil {
+				return fmt.Errorf("failed to load wallet: %w", err)
+			}
+
+			if err := store.SaveWallet(data, password); err != nil {
+				return fmt.Errorf("failed to save wallet: %w", err)
+			}
+
+			if err := reencryptBackups(store, "", password); err != nil {
+				return err
+			}
+
+			fmt.Println("Wallet encrypted successfully!")
+			return nil
+		},
+	}
+
+	decryptCmd = &cobra.Command{
+		Use:   "decrypt",
+		Short: "Store the wallet file unencrypted",
+		Long:  `Decrypt the wallet and store it as plaintext JSON. The mnemonic and spend secret will be readable by anyone with access to the datadir.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := openStorage()
+			if err != nil {
+				return err
+			}
+
+			encrypted, err := store.IsEncrypted()
+			if err != nil {
+				return err
+			}
+			if !encrypted {
+				return fmt.Errorf("wallet is not encrypted")
+			}
+
+			fmt.Println("Warning: the decrypted wallet exposes your mnemonic and spend secret to anyone who can read", store.WalletPath())
+			if !confirm("Do you want to continue?") {
+				fmt.Println("Operation cancelled.")
+				return nil
+			}
+
+			password, err := readPassword("Enter wallet password: ")
+			if err != nil {
+				return err
+			}
+
+			lock, err := lockWallet(wallet.LockExclusive)
+			if err != nil {
+				return err
+			}
+			defer lock.Release()
+
+			data, err := store.LoadWallet(password)
+			if err != nil {
+				return fmt.Errorf("failed to load wallet: %w", err)
+			}
+
+			if err := store.SaveWalletPlaintext(data); err != nil {
+				return fmt.Errorf("failed to save wallet: %w", err)
+			}
+
+			fmt.Println("Wallet decrypted successfully!")
+			return nil
+		},
+	}
+
+	changePasswordCmd = &cobra.Command{
+		Use:   "change-password",
+		Short: "Change the wallet password",
+		Long: `Re-encrypt the wallet and its backups with a new password.
+Backups still encrypted with an even older password are removed.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := openStorage()
+			if err != nil {
+				return err
+			}
+
+			encrypted, err := store.IsEncrypted()
+			if err != nil {
+				return err
+			}
+			if !encrypted {
+				return fmt.Errorf("wallet is not encrypted, use 'wallet encrypt' instead")
+			}
+
+			password, err := readPassword("Enter current wallet password: ")
+			if err != nil {
+				return err
+			}
+
+			// check the password before asking for a new one, the data is loaded again under the lock
+			if _, err := store.LoadWallet(password); err != nil {
+				return fmt.Errorf("failed to load wallet: %w", err)
+			}
+
+			newPassword, err := readNewPassword()
+			if err != nil {
+				return err
+			}
+
+			lock, err := lockWallet(wallet.LockExclusive)
+			if err != nil {
+				return err
+			}
+			defer lock.Release()
+
+			data, err := store.LoadWallet(password)
+			if err != nil {
+				return fmt.Errorf("failed to load wallet: %w", err)
+			}
+
+			if err := store.SaveWallet(data, newPassword); err != nil {
+				return fmt.Errorf("failed to save wallet: %w", err)
+			}
+
+			if err := reencryptBackups(store, password, newPassword); err != nil {
+				return err
+			}
+
+			fmt.Println("Password changed successfully!")
+			return nil
+		},
+	}
+)
+
+// reencryptBackups encrypts the backups with the new password so no plaintext or old password copies remain
+func reencryptBackups(store *storage.Storage, oldPassword, newPassword string) error {
+	removed, err := store.ReencryptBackups(oldPassword, newPassword)
+	if err != nil {
+		return fmt.Errorf("failed to re-encrypt backups: %w", err)
+	}
+	if removed > 0 {
+		fmt.Printf("Removed %d backups that were encrypted with an older password.\n", removed)
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package wallet
+
+import (
+	"strings"
+
+	"github.com/setavenger/blindbit-wallet-cli/pkg/wallet"
+)
+
+// feeRateFlag is a --fee-rate flag in sat/vB, fractional rates like 1.5 are allowed
+type feeRateFlag struct {
+	wallet.FeeRate
+}
+
+func (f *feeRateFlag) Set(s string) error {
+	rate, err := wallet.ParseFeeRate(s)
+	if err != nil {
+		return err
+	}
+	f.FeeRate = rate
+	return nil
+}
+
+func (f *feeRateFlag) String() string {
+	if f.IsZero() {
+		return ""
+	}
+	return strings.TrimSuffix(f.FeeRate.String(), " sat/vB")
+}
+
+func (f *feeRateFlag) Type() string {
+	return "sat/vB"
+}
@@ -0,0 +1,62 @@
+package wallet
+
+import (
+	"fmt"
+
+	"github.com/setavenger/blindbit-wallet-cli/pkg/wallet"
+	"github.com/spf13/cobra"
+)
+
+func NewFreezeCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "freeze <txid:vout>...",
+		Short: "Keep coins out of automatic coin selection",
+		Long: `Freeze coins so 'wallet send' never selects them, e.g. to keep coins from different sources apart.
+Frozen coins stay frozen until 'wallet unfreeze', they are marked in 'wallet utxos'.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return updateFrozen(args, (*wallet.WalletData).Freeze, "Frozen")
+		},
+	}
+}
+
+func NewUnfreezeCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "unfreeze <txid:vout>...",
+		Short: "Make frozen coins selectable again",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return updateFrozen(args, (*wallet.WalletData).Unfreeze, "Unfrozen")
+		},
+	}
+}
+
+// updateFrozen applies update to every outpoint and saves the wallet if all succeeded
+func updateFrozen(args []string, update func(*wallet.WalletData, string) error, done string) error {
+	handle, err := loadWallet(wallet.LockExclusive)
+	if err != nil {
+		return err
+	}
+	defer handle.close()
+
+	var outpoints []string
+	for _, arg := range args {
+		outpoint, err := wallet.ParseOutpoint(arg)
+		if err != nil {
+			return err
+		}
+		if err := update(handle.Data, outpoint); err != nil {
+			return err
+		}
+		outpoints = append(outpoints, outpoint)
+	}
+
+	if err := handle.save(); err != nil {
+		return fmt.Errorf("failed to save wallet data: %w", err)
+	}
+
+	for _, outpoint := range outpoints {
+		fmt.Println(done, outpoint)
+	}
+	return nil
+}
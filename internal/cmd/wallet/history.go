@@ -0,0 +1,128 @@
+package wallet
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/setavenger/blindbit-wallet-cli/pkg/wallet"
+	"github.com/spf13/cobra"
+)
+
+const historyDateFormat = "2006-01-02"
+
+func NewHistoryCmd() *cobra.Command {
+	var (
+		since, until string
+		labelRefs    []string
+		kind         string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "Show the transaction history",
+		Long: `List the transactions of the wallet: coins received (found by 'wallet sync') and transactions sent by this wallet.
+NET is the change of the balance including the fee. The fee is only known for transactions sent by this wallet.
+Coins spent by another wallet holding the same keys show up as received only.`,
+		Example: `  blindbit-wallet-cli wallet history --since 2025-01-01
+  blindbit-wallet-cli wallet history --label exchange-withdrawals --type received`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var from, to time.Time
+			var err error
+			if since != "" {
+				if from, err = time.ParseInLocation(historyDateFormat, since, time.Local); err != nil {
+					return fmt.Errorf("invalid --since date, use YYYY-MM-DD: %w", err)
+				}
+			}
+			if until != "" {
+				if to, err = time.ParseInLocation(historyDateFormat, until, time.Local); err != nil {
+					return fmt.Errorf("invalid --until date, use YYYY-MM-DD: %w", err)
+				}
+				// include the whole day
+				to = to.AddDate(0, 0, 1)
+			}
+			switch wallet.TxKind(kind) {
+			case "", wallet.TxReceived, wallet.TxSent, wallet.TxSelf:
+			default:
+				return fmt.Errorf("invalid --type %q, use received, sent or self", kind)
+			}
+
+			handle, err := loadWallet(wallet.LockShared)
+			if err != nil {
+				return err
+			}
+			defer handle.close()
+			walletData := handle.Data
+
+			var labels []uint32
+			for _, ref := range labelRefs {
+				if label, err := walletData.FindLabel(ref); err == nil {
+					labels = append(labels, label.M)
+					continue
+				}
+				// labels handed out before they were recorded only have a number
+				m, err := strconv.ParseUint(ref, 10, 32)
+				if err != nil {
+					return fmt.Errorf("%w: %s", wallet.ErrLabelNotFound, ref)
+				}
+				labels = append(labels, uint32(m))
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			defer w.Flush()
+
+			fmt.Fprintln(w, "DATE\tTYPE\tNET\tFEE\tLABELS\tSTATUS\tTXID")
+			for _, record := range walletData.History {
+				if !from.IsZero() && record.Timestamp.Before(from) {
+					continue
+				}
+				if !to.IsZero() && !record.Timestamp.Before(to) {
+					continue
+				}
+				if kind != "" && record.Kind() != wallet.TxKind(kind) {
+					continue
+				}
+				if len(labels) > 0 && !slices.ContainsFunc(record.Labels, func(m uint32) bool { return slices.Contains(labels, m) }) {
+					continue
+				}
+
+				fee := ""
+				if record.Fee > 0 {
+					fee = fmt.Sprint(record.Fee)
+				}
+
+				var labelNames []string
+				for _, m := range record.Labels {
+					labelNames = append(labelNames, walletData.LabelName(m))
+				}
+
+				status := "confirmed"
+				if walletData.TxPending(record) {
+					status = "pending"
+				}
+
+				fmt.Fprintf(w, "%s\t%s\t%+d\t%s\t%s\t%s\t%s\n",
+					record.Timestamp.Local().Format("2006-01-02 15:04:05"),
+					record.Kind(),
+					record.Net(),
+					fee,
+					strings.Join(labelNames, ","),
+					status,
+					record.Txid)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&since, "since", "", "Only show transactions on or after this date (YYYY-MM-DD)")
+	cmd.Flags().StringVar(&until, "until", "", "Only show transactions on or before this date (YYYY-MM-DD)")
+	cmd.Flags().StringSliceVar(&labelRefs, "label", nil, "Only show transactions paying to these labels (name or m), can be repeated")
+	cmd.Flags().StringVar(&kind, "type", "", "Only show received, sent or self transactions")
+
+	return cmd
+}
@@ -1,9 +1,10 @@
 package wallet
 
 import (
+	"bufio"
+	"encoding/hex"
 	"fmt"
 	"os"
-	"path/filepath"
 	"strings"
 
 	"github.com/setavenger/blindbit-wallet-cli/pkg/wallet"
@@ -15,56 +16,118 @@ import (
 var importCmd = &cobra.Command{
 	Use:   "import",
 	Short: "Import a wallet from a mnemonic",
-	Long:  `Import an existing wallet using its mnemonic (seed phrase). The mnemonic will be read securely from stdin. The wallet will be stored in the configured datadir.`,
+	Long: `Import an existing wallet using its mnemonic (seed phrase). The mnemonic will be read securely from stdin. The wallet will be stored encrypted in the configured datadir.
+
+With --watch-only the wallet is imported from the scan secret and spend public key shown by 'wallet info'.
+A watch-only wallet can sync and build unsigned PSBTs but never holds the spend secret.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		datadir := viper.GetString("datadir")
-		walletPath := filepath.Join(datadir, "wallet.json")
+		walletdir := viper.GetString("walletdir")
+
+		store, err := openStorage()
+		if err != nil {
+			return err
+		}
 
 		// Check if wallet already exists
-		_, err := os.Stat(walletPath)
-		if err == nil {
-			fmt.Println("Warning: A wallet already exists at:", walletPath)
+		exists := store.WalletExists()
+		if exists {
+			fmt.Println("Warning: A wallet already exists at:", store.WalletPath())
 			fmt.Println("Creating a new wallet will overwrite the existing one.")
 
-			// Ask for confirmation
-			fmt.Print("Do you want to continue? (y/N): ")
-			var response string
-			fmt.Scanln(&response)
-
-			if response != "y" && response != "Y" {
+			if !confirm("Do you want to continue?") {
 				fmt.Println("Operation cancelled.")
 				return nil
 			}
-		} else if !os.IsNotExist(err) {
-			return fmt.Errorf("failed to check wallet file: %w", err)
 		}
 
-		fmt.Print("Enter your mnemonic (seed phrase): ")
+		network := wallet.Network(viper.GetString("network"))
+		if cmd.Flags().Changed("network") {
+			network = wallet.Network(cmd.Flag("network").Value.String())
+		}
 
-		// Read password securely
-		bytePassword, err := term.ReadPassword(int(os.Stdin.Fd()))
-		if err != nil {
-			return fmt.Errorf("failed to read mnemonic: %w", err)
+		birthHeight, _ := cmd.Flags().GetInt64("birth-height")
+		if birthHeight < 0 {
+			return fmt.Errorf("--birth-height must not be negative")
 		}
 
-		// Convert to string and trim whitespace
-		mnemonic := strings.TrimSpace(string(bytePassword))
-		fmt.Println() // Add newline after password input
+		var w *wallet.Wallet
+		if watchOnly, _ := cmd.Flags().GetBool("watch-only"); watchOnly {
+			if usePassphrase, _ := cmd.Flags().GetBool("passphrase"); usePassphrase {
+				return fmt.Errorf("--passphrase can't be used with --watch-only")
+			}
+			w, err = readWatchOnlyWallet(cmd, network)
+			if err != nil {
+				return fmt.Errorf("failed to import wallet: %w", err)
+			}
+		} else {
+			fmt.Print("Enter your mnemonic (seed phrase): ")
 
-		network := wallet.Network(viper.GetString("network"))
-		if cmd.Flags().Changed("network") {
-			network = wallet.Network(cmd.Flag("network").Value.String())
+			// Read password securely
+			bytePassword, err := term.ReadPassword(int(os.Stdin.Fd()))
+			if err != nil {
+				return fmt.Errorf("failed to read mnemonic: %w", err)
+			}
+
+			// Convert to string and trim whitespace
+			mnemonic := strings.TrimSpace(string(bytePassword))
+			fmt.Println() // Add newline after password input
+
+			var passphrase string
+			if usePassphrase, _ := cmd.Flags().GetBool("passphrase"); usePassphrase {
+				passphrase, err = readBIP39Passphrase(false)
+				if err != nil {
+					return err
+				}
+			}
+
+			w, err = wallet.Import(mnemonic, passphrase, network)
+			if err != nil {
+				return fmt.Errorf("failed to import wallet: %w", err)
+			}
 		}
 
-		w, err := wallet.Import(datadir, mnemonic, network)
+		password, err := readNewPassword()
 		if err != nil {
-			return fmt.Errorf("failed to import wallet: %w", err)
+			return err
+		}
+
+		data := wallet.NewWalletData(w)
+		data.BirthHeight = birthHeight
+
+		lock, err := lockWallet(wallet.LockExclusive)
+		if err != nil {
+			return err
+		}
+		defer lock.Release()
+
+		if !exists && store.WalletExists() {
+			return fmt.Errorf("a wallet named %q was created in the meantime", viper.GetString("wallet"))
+		}
+
+		if err := store.SaveWallet(data, password); err != nil {
+			return fmt.Errorf("failed to save wallet: %w", err)
 		}
 
 		fmt.Println("\nWallet imported successfully!")
 		fmt.Println("Network:", w.Network)
 		fmt.Println("Created at:", w.CreatedAt)
-		fmt.Printf("Wallet stored in: %s\n", datadir)
+		if data.BirthHeight == 0 {
+			fmt.Println("Birth height: unknown (set --birth-height to let rescans skip older blocks)")
+		} else {
+			fmt.Println("Birth height:", data.BirthHeight)
+		}
+		if w.IsWatchOnly() {
+			fmt.Println("Watch-only: yes (transactions are created as unsigned PSBTs)")
+		}
+		address, err := w.Address()
+		if err != nil {
+			return fmt.Errorf("failed to get address: %w", err)
+		}
+		fmt.Println("Address:", address)
+		if w.HasPassphrase {
+			fmt.Println("BIP39 passphrase: yes (check that the address matches what you expect)")
+		}
+		fmt.Printf("Wallet %q stored in: %s\n", viper.GetString("wallet"), walletdir)
 
 		return nil
 	},
@@ -73,4 +136,43 @@ var importCmd = &cobra.Command{
 func init() {
 	// Add network flag
 	importCmd.Flags().String("network", "mainnet", "Network to use (mainnet, testnet, signet, regtest)")
+	importCmd.Flags().Bool("passphrase", false, "The wallet uses a BIP39 passphrase (prompted securely)")
+	importCmd.Flags().Bool("watch-only", false, "Import a watch-only wallet from a scan secret and spend public key instead of a mnemonic")
+	importCmd.Flags().Int64("birth-height", 0, "Block height before the wallet's first transaction, rescans start there")
+	importCmd.Flags().String("spend-pub", "", "Spend public key (hex) for --watch-only, prompted if not set")
+}
+
+// readWatchOnlyWallet reads the scan secret securely and the spend public key from the flag or stdin
+func readWatchOnlyWallet(cmd *cobra.Command, network wallet.Network) (*wallet.Wallet, error) {
+	fmt.Print("Enter the scan secret (hex): ")
+	byteScanSecret, err := term.ReadPassword(int(os.Stdin.Fd()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scan secret: %w", err)
+	}
+	fmt.Println()
+
+	scanSecret, err := hex.DecodeString(strings.TrimSpace(string(byteScanSecret)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode scan secret: %w", err)
+	}
+
+	spendPubHex, _ := cmd.Flags().GetString("spend-pub")
+	if spendPubHex == "" {
+		fmt.Print("Enter the spend public key (hex): ")
+		spendPubHex, err = bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("failed to read spend public key: %w", err)
+		}
+	}
+
+	spendPublic, err := hex.DecodeString(strings.TrimSpace(spendPubHex))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode spend public key: %w", err)
+	}
+
+	return wallet.NewWatchOnly(wallet.ScanOnlyParams{
+		ScanSecret:  scanSecret,
+		SpendPublic: spendPublic,
+		Network:     network,
+	})
 }
@@ -6,7 +6,6 @@ import (
 
 	"github.com/setavenger/blindbit-wallet-cli/pkg/wallet"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 )
 
 var infoCmd = &cobra.Command{
@@ -14,11 +13,12 @@ var infoCmd = &cobra.Command{
 	Short: "Show wallet information",
 	Long:  `Display wallet information including network, scan secret, and spend public key.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		datadir := viper.GetString("datadir")
-		w, err := wallet.Load(datadir)
+		handle, err := loadWallet(wallet.LockShared)
 		if err != nil {
-			return fmt.Errorf("failed to load wallet: %w", err)
+			return err
 		}
+		defer handle.close()
+		w := &handle.Data.Wallet
 
 		pubKey := w.PubKeySpend()
 
@@ -26,6 +26,12 @@ var infoCmd = &cobra.Command{
 		fmt.Println("-------------------")
 		fmt.Println("Network:", w.Network)
 		fmt.Println("Created at:", w.CreatedAt)
+		if w.IsWatchOnly() {
+			fmt.Println("Type: watch-only (can not sign)")
+		} else {
+			fmt.Println("BIP39 Passphrase:", w.HasPassphrase)
+		}
+		fmt.Println("Birth Height:", handle.Data.BirthHeight)
 		fmt.Println("Scan Secret:", hex.EncodeToString(w.ScanSecret))
 		fmt.Println("Spend Public:", hex.EncodeToString(pubKey[:]))
 
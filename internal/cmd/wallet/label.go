@@ -0,0 +1,119 @@
+package wallet
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/setavenger/blindbit-wallet-cli/pkg/wallet"
+	"github.com/spf13/cobra"
+)
+
+func NewLabelCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "label",
+		Short: "Manage named labels",
+		Long: `Labels give every counterparty its own silent payment address, all of them are found with a single scan.
+Each label has a number (m) and a name, commands accept either to refer to a label.
+blindbit-scan has no API to register labels, it scans the change label and labels 1 to wallet.label_count
+of its own config. Set wallet.label_count to at least the highest label number and restart the daemon.`,
+	}
+
+	cmd.AddCommand(newLabelCreateCmd())
+	cmd.AddCommand(newLabelListCmd())
+	cmd.AddCommand(newLabelRenameCmd())
+
+	return cmd
+}
+
+func newLabelCreateCmd() *cobra.Command {
+	var name string
+
+	cmd := &cobra.Command{
+		Use:     "create",
+		Short:   "Create a label with the next free number",
+		Example: `  blindbit-wallet-cli wallet label create --name "exchange-withdrawals"`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			handle, err := loadWallet(wallet.LockExclusive)
+			if err != nil {
+				return err
+			}
+			defer handle.close()
+
+			label, err := handle.Data.CreateLabel(name)
+			if err != nil {
+				return err
+			}
+
+			if err := handle.save(); err != nil {
+				return fmt.Errorf("failed to save wallet data: %w", err)
+			}
+
+			fmt.Printf("Label %q created (M=%d)\n", label.Name, label.M)
+			fmt.Println("Silent Payment Address:")
+			fmt.Println(label.Address)
+			fmt.Println(labelCountHint(highestLabel(handle.Data)))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&name, "name", "", "Name of the label")
+	_ = cmd.MarkFlagRequired("name")
+
+	return cmd
+}
+
+func newLabelListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List the labels of the wallet",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			handle, err := loadWallet(wallet.LockShared)
+			if err != nil {
+				return err
+			}
+			defer handle.close()
+
+			if len(handle.Data.Labels) == 0 {
+				fmt.Println("No labels, create one with 'wallet label create --name <name>'.")
+				return nil
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+			fmt.Fprintln(w, "M\tNAME\tADDRESS")
+			for _, label := range handle.Data.Labels {
+				fmt.Fprintf(w, "%d\t%s\t%s\n", label.M, label.Name, label.Address)
+			}
+			w.Flush()
+			fmt.Printf("\nThe scan daemon needs wallet.label_count of at least %d to find all of them.\n", highestLabel(handle.Data))
+			return nil
+		},
+	}
+}
+
+func newLabelRenameCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "rename <name|m> <new-name>",
+		Short: "Rename a label",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			handle, err := loadWallet(wallet.LockExclusive)
+			if err != nil {
+				return err
+			}
+			defer handle.close()
+
+			if err := handle.Data.RenameLabel(args[0], args[1]); err != nil {
+				return err
+			}
+
+			if err := handle.save(); err != nil {
+				return fmt.Errorf("failed to save wallet data: %w", err)
+			}
+
+			fmt.Printf("Label %s renamed to %q\n", args[0], args[1])
+			return nil
+		},
+	}
+}
@@ -0,0 +1,126 @@
+package wallet
+
+import (
+	"fmt"
+
+	scanwallet "github.com/setavenger/blindbit-scan/pkg/wallet"
+	"github.com/setavenger/blindbit-wallet-cli/pkg/wallet"
+	"github.com/setavenger/go-bip352"
+	"github.com/spf13/cobra"
+)
+
+func NewMigrateDerivationCmd() *cobra.Command {
+	var (
+		feeRate feeRateFlag
+		apply   bool
+		force   bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "migrate-derivation",
+		Short: "Move a wallet created with the legacy key derivation to the standard BIP39 derivation",
+		Long: `Early versions of 'wallet new' derived keys from the raw mnemonic entropy instead of the BIP39 seed.
+Such wallets can NOT be restored from their mnemonic with 'wallet import' or any other BIP352 wallet.
+
+This command detects affected wallets and shows the legacy and the corrected address.
+  1. Run 'wallet sync' so the local UTXOs are up to date.
+  2. Run with --fee-rate to create a transaction sweeping all funds to the corrected address and broadcast it.
+  3. Once the sweep confirmed and 'wallet sync' shows no unspent coins, run with --apply to switch the wallet to the corrected keys.
+Applying keeps the birth height, history, frozen coins and labels (re-derived for the new keys),
+the UTXOs start empty. Afterwards point your scan daemon at the new keys with 'wallet register' and sync.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			handle, err := loadWallet(wallet.LockExclusive)
+			if err != nil {
+				return err
+			}
+			defer handle.close()
+			w := handle.Data.Wallet
+
+			legacy, err := w.UsesLegacyDerivation()
+			if err != nil {
+				return err
+			}
+			if !legacy {
+				fmt.Println("Wallet uses the standard BIP39 derivation, nothing to migrate.")
+				return nil
+			}
+
+			corrected, err := w.CorrectedWallet()
+			if err != nil {
+				return fmt.Errorf("failed to derive corrected keys: %w", err)
+			}
+
+			legacyAddress, err := bip352.CreateAddress(w.PubKeyScan(), w.PubKeySpend(), w.Network == wallet.NetworkMainnet, 0)
+			if err != nil {
+				return fmt.Errorf("failed to create address: %w", err)
+			}
+			correctedAddress, err := bip352.CreateAddress(corrected.PubKeyScan(), corrected.PubKeySpend(), corrected.Network == wallet.NetworkMainnet, 0)
+			if err != nil {
+				return fmt.Errorf("failed to create address: %w", err)
+			}
+
+			var unspent scanwallet.UtxoCollection
+			var unspentAmount uint64
+			for _, u := range handle.Data.UTXOs {
+				if u.State != scanwallet.StateUnspent && u.State != scanwallet.StateUnconfirmed {
+					continue
+				}
+				unspentAmount += u.Amount
+				if u.State == scanwallet.StateUnspent {
+					unspent = append(unspent, &u)
+				}
+			}
+
+			fmt.Println("This wallet uses the LEGACY key derivation.")
+			fmt.Println("Legacy address (current):   ", legacyAddress)
+			fmt.Println("Corrected address (mnemonic):", correctedAddress)
+			fmt.Printf("Funds on legacy keys (last sync at height %d): %d sats in %d UTXOs\n",
+				handle.Data.LastHeight, unspentAmount, len(unspent))
+
+			if cmd.Flags().Changed("fee-rate") {
+				chainParams, err := w.Network.ChainParams()
+				if err != nil {
+					return err
+				}
+
+				txBytes, err := w.Sweep(unspent, correctedAddress, feeRate.FeeRate, chainParams)
+				if err != nil {
+					return fmt.Errorf("failed to create sweep transaction: %w", err)
+				}
+
+				fmt.Println("\nSweep transaction (broadcast it, then wait for confirmation):")
+				fmt.Printf("%x\n", txBytes)
+				return nil
+			}
+
+			if !apply {
+				fmt.Println("\nRun with --fee-rate <sat/vB> to sweep the funds, then with --apply to switch keys.")
+				return nil
+			}
+
+			if unspentAmount > 0 && !force {
+				return fmt.Errorf("%d sats are still on the legacy keys, sweep them and sync first (or use --force)", unspentAmount)
+			}
+
+			// The old UTXOs and scan progress belong to the legacy keys, the labels are re-derived
+			handle.Data, err = handle.Data.SwitchKeys(corrected)
+			if err != nil {
+				return fmt.Errorf("failed to switch keys: %w", err)
+			}
+			if err := handle.save(); err != nil {
+				return fmt.Errorf("failed to save wallet: %w", err)
+			}
+
+			fmt.Println("\nWallet switched to the corrected keys.")
+			fmt.Println("Birth height, history, frozen coins and labels were kept, the labels have new addresses.")
+			fmt.Println("Point your scan daemon at the new keys with 'wallet register' and run 'wallet sync'.")
+			return nil
+		},
+	}
+
+	cmd.Flags().Var(&feeRate, "fee-rate", "Create a sweep transaction to the corrected address with this fee rate in sat/vB")
+	cmd.Flags().BoolVar(&apply, "apply", false, "Replace the legacy keys with the corrected keys")
+	cmd.Flags().BoolVar(&force, "force", false, "Apply even if funds remain on the legacy keys")
+
+	return cmd
+}
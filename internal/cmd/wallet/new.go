@@ -2,8 +2,6 @@ package wallet
 
 import (
 	"fmt"
-	"os"
-	"path/filepath"
 
 	"github.com/setavenger/blindbit-wallet-cli/pkg/wallet"
 	"github.com/spf13/cobra"
@@ -13,57 +11,142 @@ import (
 var newCmd = &cobra.Command{
 	Use:   "new",
 	Short: "Create a new wallet",
-	Long:  `Generate a new wallet with a random mnemonic phrase.`,
+	Long:  `Generate a new wallet with a random mnemonic phrase. The wallet file is encrypted with a password you choose.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		datadir := viper.GetString("datadir")
-		walletPath := filepath.Join(datadir, "wallet.json")
+		return runNew(cmd, true)
+	},
+}
 
-		// Create datadir if it doesn't exist
-		if err := os.MkdirAll(datadir, 0700); err != nil {
-			return fmt.Errorf("failed to create datadir: %w", err)
+var createCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create a new named wallet",
+	Long: `Generate a new wallet with a random mnemonic phrase under the given name.
+Unlike 'wallet new' this never overwrites an existing wallet. Select it later with --wallet <name>
+or make it the default with default_wallet in blindbit.toml.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name, _ := cmd.Flags().GetString("name")
+		if err := SelectWallet(name); err != nil {
+			return err
 		}
+		return runNew(cmd, false)
+	},
+}
+
+// runNew creates a new wallet in the active wallet directory.
+// With overwrite set the user may replace an existing wallet after confirming.
+func runNew(cmd *cobra.Command, overwrite bool) error {
+	walletdir := viper.GetString("walletdir")
 
-		// Check if wallet already exists
-		_, err := os.Stat(walletPath)
-		if err == nil {
-			fmt.Println("Warning: A wallet already exists at:", walletPath)
-			fmt.Println("Creating a new wallet will overwrite the existing one.")
-
-			// Ask for confirmation
-			fmt.Print("Do you want to continue? (y/N): ")
-			var response string
-			fmt.Scanln(&response)
-
-			if response != "y" && response != "Y" {
-				fmt.Println("Operation cancelled.")
-				return nil
-			}
-		} else if !os.IsNotExist(err) {
-			return fmt.Errorf("failed to check wallet file: %w", err)
+	// Creates the wallet directory if it doesn't exist
+	store, err := openStorage()
+	if err != nil {
+		return err
+	}
+
+	// Check if wallet already exists
+	exists := store.WalletExists()
+	if exists {
+		if !overwrite {
+			return fmt.Errorf("a wallet named %q already exists", viper.GetString("wallet"))
 		}
 
-		// Create new wallet
-		network := wallet.Network(viper.GetString("network"))
-		if cmd.Flags().Changed("network") {
-			network = wallet.Network(cmd.Flag("network").Value.String())
+		fmt.Println("Warning: A wallet already exists at:", store.WalletPath())
+		fmt.Println("Creating a new wallet will overwrite the existing one.")
+
+		if !confirm("Do you want to continue?") {
+			fmt.Println("Operation cancelled.")
+			return nil
 		}
-		w, err := wallet.New(datadir, network)
+	}
+
+	// Create new wallet
+	network := wallet.Network(viper.GetString("network"))
+	if cmd.Flags().Changed("network") {
+		network = wallet.Network(cmd.Flag("network").Value.String())
+	}
+	var passphrase string
+	if usePassphrase, _ := cmd.Flags().GetBool("passphrase"); usePassphrase {
+		passphrase, err = readBIP39Passphrase(true)
 		if err != nil {
-			return fmt.Errorf("failed to create wallet: %w", err)
+			return err
 		}
+	}
 
-		fmt.Println("Wallet created successfully!")
-		fmt.Printf("Network: %s\n", w.Network)
-		fmt.Printf("Created at: %s\n", w.CreatedAt)
-		fmt.Printf("Wallet stored in: %s\n", datadir)
-		fmt.Println("\nIMPORTANT: Save your mnemonic phrase securely!")
-		fmt.Printf("Mnemonic: %s\n", w.Mnemonic)
+	w, err := wallet.New(passphrase, network)
+	if err != nil {
+		return fmt.Errorf("failed to create wallet: %w", err)
+	}
 
-		return nil
-	},
+	data := wallet.NewWalletData(w)
+	data.BirthHeight, err = newWalletBirthHeight(cmd)
+	if err != nil {
+		return err
+	}
+
+	password, err := readNewPassword()
+	if err != nil {
+		return err
+	}
+
+	lock, err := lockWallet(wallet.LockExclusive)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	if !exists && store.WalletExists() {
+		return fmt.Errorf("a wallet named %q was created in the meantime", viper.GetString("wallet"))
+	}
+
+	if err := store.SaveWallet(data, password); err != nil {
+		return fmt.Errorf("failed to save wallet: %w", err)
+	}
+
+	fmt.Println("Wallet created successfully!")
+	fmt.Printf("Network: %s\n", w.Network)
+	fmt.Printf("Created at: %s\n", w.CreatedAt)
+	fmt.Printf("Birth height: %d\n", data.BirthHeight)
+	fmt.Printf("Wallet %q stored in: %s\n", viper.GetString("wallet"), walletdir)
+	fmt.Println("\nIMPORTANT: Save your mnemonic phrase securely!")
+	fmt.Printf("Mnemonic: %s\n", w.Mnemonic)
+	if w.HasPassphrase {
+		fmt.Println("\nThis wallet uses a BIP39 passphrase. You need BOTH the mnemonic and the passphrase to restore it.")
+	}
+
+	return nil
 }
 
 func init() {
 	// Add network flag
 	newCmd.Flags().String("network", "mainnet", "Network to use (mainnet, testnet, signet, regtest)")
+	newCmd.Flags().Bool("passphrase", false, "Protect the seed with an additional BIP39 passphrase (prompted securely)")
+	newCmd.Flags().Int64("birth-height", -1, "Birth height of the wallet (default is the current tip of the scan daemon)")
+
+	createCmd.Flags().String("name", "", "Name of the new wallet")
+	createCmd.Flags().String("network", "mainnet", "Network to use (mainnet, testnet, signet, regtest)")
+	createCmd.Flags().Bool("passphrase", false, "Protect the seed with an additional BIP39 passphrase (prompted securely)")
+	createCmd.Flags().Int64("birth-height", -1, "Birth height of the wallet (default is the current tip of the scan daemon)")
+	createCmd.MarkFlagRequired("name")
+}
+
+// newWalletBirthHeight returns --birth-height if given, otherwise the current tip of the scan daemon.
+// A fresh wallet can't have received coins before it existed, so rescans can start there.
+func newWalletBirthHeight(cmd *cobra.Command) (int64, error) {
+	if birthHeight, _ := cmd.Flags().GetInt64("birth-height"); birthHeight >= 0 {
+		return birthHeight, nil
+	}
+
+	client, closeClient, err := newScanClient()
+	if err != nil {
+		return 0, err
+	}
+	defer closeClient()
+
+	height, err := client.GetCurrentHeight()
+	if err != nil {
+		fmt.Printf("Warning: could not get the current height from the scan daemon (%v).\n", err)
+		fmt.Println("The birth height stays unknown, rescans will have to start from the beginning. Use --birth-height to set it.")
+		return 0, nil
+	}
+	return int64(height), nil
 }
@@ -0,0 +1,76 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	scanclient "github.com/setavenger/blindbit-wallet-cli/pkg/clients/blindbitscan"
+	"github.com/setavenger/blindbit-wallet-cli/pkg/wallet"
+	"github.com/spf13/cobra"
+)
+
+func NewRegisterCmd() *cobra.Command {
+	var birthHeight int64
+
+	cmd := &cobra.Command{
+		Use:   "register",
+		Short: "Register the wallet keys with blindbit-scan",
+		Long: `Push the scan secret, spend public key and birth height to the configured blindbit-scan daemon,
+so it starts scanning for this wallet. The daemon only ever receives the scan secret, never the spend secret.
+The address the daemon derives from the keys is checked against the local address.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			handle, err := loadWallet(wallet.LockShared)
+			if err != nil {
+				return err
+			}
+			defer handle.close()
+			w := handle.Data.Wallet
+
+			if !cmd.Flags().Changed("birth-height") {
+				birthHeight = handle.Data.BirthHeight
+			}
+			if birthHeight < 0 {
+				return fmt.Errorf("--birth-height must not be negative")
+			}
+
+			localAddress, err := w.Address()
+			if err != nil {
+				return fmt.Errorf("failed to create address: %w", err)
+			}
+
+			client, closeClient, err := newScanClient()
+			if err != nil {
+				return err
+			}
+			defer closeClient()
+
+			spendPub := w.PubKeySpend()
+			daemonAddress, err := client.PutSilentPaymentKeys(scanclient.SetupReq{
+				ScanSecret:  hex.EncodeToString(w.ScanSecret),
+				SpendPublic: hex.EncodeToString(spendPub[:]),
+				BirthHeight: uint(birthHeight),
+			})
+			if err != nil {
+				return fmt.Errorf("failed to register keys: %w", err)
+			}
+
+			if daemonAddress != localAddress {
+				return fmt.Errorf("daemon derived address %s but the wallet address is %s, check that the daemon runs on %s",
+					daemonAddress, localAddress, w.Network)
+			}
+
+			fmt.Println("Keys registered with blindbit-scan.")
+			fmt.Println("Address:", daemonAddress)
+			if birthHeight == 0 {
+				fmt.Println("Birth height: unknown, the daemon scans from its configured start height")
+			} else {
+				fmt.Println("Birth height:", birthHeight)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().Int64Var(&birthHeight, "birth-height", 0, "Scan from this height instead of the wallet's birth height")
+
+	return cmd
+}
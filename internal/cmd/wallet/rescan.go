@@ -0,0 +1,157 @@
+package wallet
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/setavenger/blindbit-wallet-cli/pkg/wallet"
+	"github.com/spf13/cobra"
+)
+
+// rescanSettledPolls is how many polls at the target height without ever seeing the daemon
+// go back are accepted as "done". Protects against polling before the daemon picked up the request.
+const rescanSettledPolls = 3
+
+func NewRescanCmd() *cobra.Command {
+	var (
+		fromHeight      int64
+		fromBirthday    bool
+		skipDaemonCheck bool
+		interval        time.Duration
+		timeout         time.Duration
+	)
+
+	cmd := &cobra.Command{
+		Use:   "rescan",
+		Short: "Let blindbit-scan rescan from a height and sync the result",
+		Long: `Trigger a rescan on the blindbit-scan daemon, wait until it caught up again and then sync the wallet.
+Use this after a restore, after registering keys late or when coins to a label were missed.
+Labels are only found up to wallet.label_count of the daemon's config, raise it and restart the daemon first.
+The wallet is only locked while reading the birth height and while syncing, not while waiting.`,
+		Example: `  blindbit-wallet-cli wallet rescan --from-birthday
+  blindbit-wallet-cli wallet rescan --from-height 840000`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if cmd.Flags().Changed("from-height") == fromBirthday {
+				return fmt.Errorf("set exactly one of --from-height or --from-birthday")
+			}
+
+			handle, err := loadWallet(wallet.LockShared)
+			if err != nil {
+				return err
+			}
+			defer handle.close()
+
+			if fromBirthday {
+				if handle.Data.BirthHeight == 0 {
+					return fmt.Errorf("the wallet has no birth height, use --from-height")
+				}
+				fromHeight = handle.Data.BirthHeight
+			}
+			// the daemon rejects rescans below height 1
+			if fromHeight < 1 {
+				return fmt.Errorf("--from-height must be at least 1")
+			}
+			handle.unlock()
+
+			client, closeClient, err := newScanClient()
+			if err != nil {
+				return err
+			}
+			defer closeClient()
+
+			// a rescan with the wrong keys would only take long to find nothing
+			if !skipDaemonCheck {
+				if err := checkDaemonKeys(client, handle.Data.Wallet); err != nil {
+					return err
+				}
+			}
+
+			// a rescan only finds labels the daemon scans for
+			if highest := highestLabel(handle.Data); highest > defaultDaemonLabelCount {
+				fmt.Println(labelCountHint(highest))
+			}
+
+			// the daemon reports its last scanned height, it has caught up once it is back there
+			target, err := client.GetCurrentHeight()
+			if err != nil {
+				return fmt.Errorf("failed to get current height: %w", err)
+			}
+			if uint64(fromHeight) > target {
+				return fmt.Errorf("--from-height %d is above the daemon's scan height %d", fromHeight, target)
+			}
+
+			if _, err := client.PostRescan(uint64(fromHeight)); err != nil {
+				return fmt.Errorf("failed to trigger rescan: %w", err)
+			}
+			fmt.Printf("Rescan from height %d to %d triggered.\n", fromHeight, target)
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+			defer stop()
+
+			var deadline <-chan time.Time
+			if timeout > 0 {
+				deadline = time.After(timeout)
+			}
+
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+
+			var started bool
+			var settledPolls int
+			for {
+				select {
+				case <-ctx.Done():
+					fmt.Println()
+					return fmt.Errorf("stopped waiting, the daemon keeps rescanning, run 'wallet sync' later")
+				case <-deadline:
+					fmt.Println()
+					return fmt.Errorf("rescan did not finish within %s, the daemon keeps rescanning, run 'wallet sync' later", timeout)
+				case <-ticker.C:
+				}
+
+				height, err := client.GetCurrentHeight()
+				if err != nil {
+					return fmt.Errorf("failed to get current height: %w", err)
+				}
+				utxos, err := client.GetUTXOs(ctx)
+				if err != nil {
+					return fmt.Errorf("failed to get UTXOs: %w", err)
+				}
+
+				if height < target {
+					started = true
+					settledPolls = 0
+				} else {
+					settledPolls++
+				}
+
+				progress := 100.0
+				if target > uint64(fromHeight) && height < target {
+					progress = float64(height-min(height, uint64(fromHeight))) / float64(target-uint64(fromHeight)) * 100
+				}
+				fmt.Printf("\rScanned height %d/%d (%.1f%%), %d UTXOs found ", height, target, progress, len(utxos))
+
+				if height >= target && (started || settledPolls >= rescanSettledPolls) {
+					break
+				}
+			}
+			fmt.Println()
+			fmt.Println("Rescan complete, syncing wallet.")
+
+			if err := handle.relock(wallet.LockExclusive); err != nil {
+				return err
+			}
+			return syncWallet(ctx, handle, client, true)
+		},
+	}
+
+	cmd.Flags().Int64Var(&fromHeight, "from-height", 0, "Rescan from this block height (at least 1)")
+	cmd.Flags().BoolVar(&fromBirthday, "from-birthday", false, "Rescan from the wallet's birth height")
+	cmd.Flags().BoolVar(&skipDaemonCheck, "skip-daemon-check", false, "Rescan even if the daemon's keys do not match the wallet")
+	cmd.Flags().DurationVar(&interval, "interval", 5*time.Second, "How often to poll the daemon for progress")
+	cmd.Flags().DurationVar(&timeout, "timeout", 0, "Give up waiting after this duration (0 waits until done)")
+
+	return cmd
+}
@@ -0,0 +1,96 @@
+package wallet
+
+import (
+	"fmt"
+	"strings"
+
+	client "github.com/setavenger/blindbit-wallet-cli/internal/client"
+	"github.com/setavenger/blindbit-wallet-cli/internal/config"
+	scanclient "github.com/setavenger/blindbit-wallet-cli/pkg/clients/blindbitscan"
+	"github.com/setavenger/blindbit-wallet-cli/pkg/wallet"
+	"github.com/spf13/viper"
+)
+
+// newScanClient creates the blindbit-scan client from the config, connecting through Tor if enabled.
+// The returned func releases the Tor client and has to be called when done.
+func newScanClient() (*scanclient.Client, func(), error) {
+	// Create Tor client if enabled
+	var torClient *client.TorClient
+	if viper.GetBool("use_tor") {
+		var err error
+		torClient, err = client.NewTorClient(&config.Config{
+			UseTor:     viper.GetBool("use_tor"),
+			TorHost:    viper.GetString("tor_host"),
+			TorPort:    viper.GetInt("tor_port"),
+			TorControl: viper.GetString("tor_control"),
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create Tor client: %w", err)
+		}
+	}
+
+	// Create blindbit-scan client
+	host := viper.GetString("scan_host")
+	port := viper.GetInt("scan_port")
+	username := viper.GetString("scan_user")
+	password := viper.GetString("scan_pass")
+
+	// Use the host directly if it already includes a protocol
+	baseURL := host
+	if !strings.HasPrefix(host, "http://") && !strings.HasPrefix(host, "https://") {
+		baseURL = fmt.Sprintf("http://%s:%d", host, port)
+	}
+
+	closeFn := func() {
+		if torClient != nil {
+			torClient.Close()
+		}
+	}
+
+	return scanclient.NewClient(baseURL, username, password, torClient), closeFn, nil
+}
+
+// checkDaemonKeys makes sure the daemon scans for this wallet by comparing the address it derives
+// from its configured keys with the local base address. A daemon set up with other keys
+// would otherwise just report no UTXOs.
+func checkDaemonKeys(client *scanclient.Client, w wallet.Wallet) error {
+	localAddress, err := w.Address()
+	if err != nil {
+		return fmt.Errorf("failed to create address: %w", err)
+	}
+
+	daemonAddress, err := client.GetAddress()
+	if err != nil {
+		return fmt.Errorf("failed to get address from scan daemon: %w", err)
+	}
+
+	if daemonAddress != localAddress {
+		return fmt.Errorf(`the scan daemon is not scanning for this wallet
+  daemon address: %s
+  wallet address: %s
+The daemon is configured with different keys or runs on another network than %s.
+Run 'wallet register' to point it at this wallet`, daemonAddress, localAddress, w.Network)
+	}
+
+	return nil
+}
+
+// defaultDaemonLabelCount is blindbit-scan's default wallet.label_count
+const defaultDaemonLabelCount = 1
+
+// labelCountHint tells how to make the daemon scan for the labels up to highest.
+// blindbit-scan has no API to register labels, it scans the change label and labels 1 to wallet.label_count
+// of its own config, read when the daemon starts.
+func labelCountHint(highest uint32) string {
+	return fmt.Sprintf("The scan daemon only finds payments to labels 1 to wallet.label_count of its config (env WALLET_LABEL_COUNT).\n"+
+		"Set it to at least %d and restart the daemon, then run 'wallet rescan' to find earlier payments.", highest)
+}
+
+// highestLabel returns the highest label number handed out, 0 without labels
+func highestLabel(data *wallet.WalletData) uint32 {
+	var highest uint32
+	for _, l := range data.Labels {
+		highest = max(highest, l.M)
+	}
+	return highest
+}
@@ -5,6 +5,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/btcsuite/btcd/btcutil/psbt"
 	"github.com/setavenger/blindbit-wallet-cli/pkg/wallet"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -12,7 +13,12 @@ import (
 
 func NewSendCmd() *cobra.Command {
 	var (
-		feeRate int32
+		feeRate       feeRateFlag
+		only          []string
+		excluded      []string
+		coinSelection string
+		subtractFee   []string
+		dryRun        bool
 	)
 
 	cmd := &cobra.Command{
@@ -23,60 +29,252 @@ The amount should be in satoshis. The command supports both regular Bitcoin addr
 
 Examples:
   blindbit-wallet-cli wallet send bc1q...:1000000
-  blindbit-wallet-cli wallet send bc1q...:1000000 sp1q...:2000000 --fee-rate 5`,
+  blindbit-wallet-cli wallet send bc1q...:1000000 sp1q...:2000000 --fee-rate 5
+  blindbit-wallet-cli wallet send sp1q...:50000 --fee-rate 1.5
+  blindbit-wallet-cli wallet send sp1q...:max --fee-rate 5
+
+A single recipient with the amount "max" gets all selectable coins minus the fee, without a change output.
+
+Coins are picked automatically from the confirmed, unfrozen coins. Use --utxo to spend exactly the given coins
+and --exclude to keep coins out of the selection, 'wallet freeze' excludes coins permanently.
+--coin-selection picks the strategy (default coin_selection from the config, else bnb):
+  bnb             coins that pay the amount without change, otherwise accumulate in wallet order
+  largest-first   fewest inputs
+  smallest-first  consolidate small coins
+  random          random order
+  oldest-first    spend the coins received first
+The chosen inputs are printed with the transaction size, fee and waste so strategies can be compared,
+--dry-run only prints them, nothing is signed or saved.
+
+--subtract-fee-from takes the fee out of a recipient's amount instead of adding it on top, give the recipient
+by its position (starting at 0) or its address. With several recipients the fee is split evenly.
+The send fails if a recipient would get less than the dust limit. Coins that cover the amounts with less than
+the dust limit left over are spent without change, the leftover pays part of the fee.
+
+Watch-only wallets print an unsigned PSBT instead, also for "max". They can't pay to other silent payment addresses.`,
 		Args: cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if feeRate < 0 {
-				return fmt.Errorf("please set a fee rate")
-			}
-
-			// Load wallet data
-			datadir := viper.GetString("datadir")
-			walletData, err := wallet.LoadData(datadir)
+			coinControl, err := parseCoinControl(only, excluded, coinSelection)
 			if err != nil {
-				return fmt.Errorf("failed to load wallet: %w", err)
+				return err
 			}
+			return runSend(cmd, args, feeRate.FeeRate, coinControl, subtractFee, dryRun)
+		},
+	}
 
-			// Get network from flag if specified, otherwise use config file value
-			if cmd.Flags().Changed("network") {
-				walletData.Wallet.Network = wallet.Network(cmd.Flag("network").Value.String())
-			} else {
-				// Use network from config file
-				configNetwork := viper.GetString("network")
-				if configNetwork != "" {
-					walletData.Wallet.Network = wallet.Network(configNetwork)
-				}
-			}
+	cmd.Flags().Var(&feeRate, "fee-rate", "Fee rate in sat/vB, fractions like 1.5 are allowed")
+	cmd.Flags().String("network", "", "Network to use (mainnet, testnet, signet, regtest)")
+	cmd.Flags().StringArrayVar(&only, "utxo", nil, "Spend exactly this coin (txid:vout), can be repeated")
+	cmd.Flags().StringArrayVar(&excluded, "exclude", nil, "Never spend this coin (txid:vout), can be repeated")
+	cmd.Flags().StringVar(&coinSelection, "coin-selection", "", "Coin selection strategy: bnb, largest-first, smallest-first, random or oldest-first")
+	cmd.Flags().StringArrayVar(&subtractFee, "subtract-fee-from", nil, "Recipient (index from 0 or address) that pays the fee, can be repeated")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Only print the coin selection, nothing is signed or saved")
+
+	return cmd
+}
+
+// runSend sends to the recipients given as address:amount, a single address:max sweeps all selectable coins.
+// subtractFee lists the recipients paying the fee by index or address. With dryRun only the coin selection is printed.
+func runSend(
+	cmd *cobra.Command,
+	args []string,
+	feeRate wallet.FeeRate,
+	coinControl wallet.CoinControl,
+	subtractFee []string,
+	dryRun bool,
+) error {
+	if feeRate.IsZero() {
+		return fmt.Errorf("please set a fee rate")
+	}
+
+	// Load wallet data, a dry run doesn't modify it
+	lock := wallet.LockExclusive
+	if dryRun {
+		lock = wallet.LockShared
+	}
+	handle, err := loadWallet(lock)
+	if err != nil {
+		return err
+	}
+	defer handle.close()
+	walletData := handle.Data
+
+	// Get network from flag if specified, otherwise use config file value.
+	// The override only applies to this send, it is not saved.
+	storedNetwork := walletData.Wallet.Network
+	if cmd.Flags().Changed("network") {
+		walletData.Wallet.Network = wallet.Network(cmd.Flag("network").Value.String())
+	} else {
+		// Use network from config file
+		configNetwork := viper.GetString("network")
+		if configNetwork != "" {
+			walletData.Wallet.Network = wallet.Network(configNetwork)
+		}
+	}
 
-			var recipients []wallet.Recipient
-			for _, arg := range args {
-				rec, err := extractRecipientFromPositionalArg(arg)
-				if err != nil {
-					return fmt.Errorf("failed to extract recipient: %w", err)
-				}
-				recipients = append(recipients, rec)
+	// address:max sweeps everything to a single recipient
+	var sweepAddress string
+	var recipients []wallet.Recipient
+	for _, arg := range args {
+		if address, ok := strings.CutSuffix(arg, ":max"); ok {
+			if len(args) > 1 {
+				return fmt.Errorf("%s can not be combined with other recipients", arg)
 			}
+			sweepAddress = address
+			break
+		}
+		rec, err := extractRecipientFromPositionalArg(arg)
+		if err != nil {
+			return fmt.Errorf("failed to extract recipient: %w", err)
+		}
+		recipients = append(recipients, rec)
+	}
+
+	if len(subtractFee) > 0 {
+		if sweepAddress != "" {
+			return fmt.Errorf("--subtract-fee-from can not be combined with max, the sweep already pays the fee")
+		}
+		coinControl.SubtractFeeFrom, err = resolveRecipients(recipients, subtractFee)
+		if err != nil {
+			return err
+		}
+	}
+
+	var selection wallet.Selection
+	if dryRun {
+		if sweepAddress != "" {
+			selection, err = wallet.SelectSweep(walletData, sweepAddress, feeRate, coinControl)
+		} else {
+			selection, err = wallet.SelectCoins(walletData, recipients, feeRate, coinControl)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to select coins: %w", err)
+		}
 
-			// Send to recipient
-			txBytes, err := wallet.SendToRecipients(
+		var inputs []string
+		for _, utxo := range selection.Inputs {
+			inputs = append(inputs, wallet.FormatOutpoint(utxo.Txid, utxo.Vout))
+		}
+		printInputs(walletData, inputs)
+		fmt.Println("Coin selection:", selection)
+		fmt.Println("Dry run, nothing was signed or saved.")
+		return nil
+	}
+
+	if walletData.Wallet.IsWatchOnly() {
+		var packet *psbt.Packet
+		if sweepAddress != "" {
+			packet, selection, err = wallet.CreateUnsignedSweep(walletData, sweepAddress, feeRate, coinControl)
+		} else {
+			packet, selection, err = wallet.CreateUnsignedTx(
 				walletData,
 				recipients,
-				uint32(feeRate),
+				feeRate,
+				coinControl,
 			)
-			if err != nil {
-				return fmt.Errorf("failed to send: %w", err)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to create transaction: %w", err)
+		}
+
+		var inputs []string
+		for _, txIn := range packet.UnsignedTx.TxIn {
+			inputs = append(inputs, txIn.PreviousOutPoint.String())
+		}
+		printInputs(walletData, inputs)
+		fmt.Println("Coin selection:", selection)
+
+		encoded, err := packet.B64Encode()
+		if err != nil {
+			return fmt.Errorf("failed to encode psbt: %w", err)
+		}
+
+		fmt.Println("Watch-only wallet, sign this PSBT with 'wallet sign-psbt' in the wallet holding the spend secret.")
+		fmt.Println("Unsigned PSBT:", encoded)
+		return nil
+	}
+
+	// Send to recipient
+	var txBytes []byte
+	if sweepAddress != "" {
+		txBytes, selection, err = wallet.SweepTo(walletData, sweepAddress, feeRate, coinControl)
+	} else {
+		txBytes, selection, err = wallet.SendToRecipients(walletData, recipients, feeRate, coinControl)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to send: %w", err)
+	}
+
+	// the spent coins are now unconfirmed_spent, keep them from being selected again
+	walletData.Wallet.Network = storedNetwork
+	if err := handle.save(); err != nil {
+		return fmt.Errorf("failed to save wallet data: %w", err)
+	}
+
+	// Print the signed transaction
+	pending := walletData.PendingTxs[len(walletData.PendingTxs)-1]
+	printInputs(walletData, pending.Inputs)
+	fmt.Println("Coin selection:", selection)
+	fmt.Printf("Signed transaction: %x\n", txBytes)
+	fmt.Println("Txid:", pending.Txid)
+	fmt.Printf("Inputs marked as unconfirmed_spent until the next sync, run 'wallet abandon %s' if it is not broadcast.\n", pending.Txid)
+	return nil
+}
+
+// resolveRecipients turns recipient references, an index starting at 0 or an address, into indices
+func resolveRecipients(recipients []wallet.Recipient, refs []string) ([]int, error) {
+	var indices []int
+	for _, ref := range refs {
+		if i, err := strconv.Atoi(ref); err == nil {
+			if i < 0 || i >= len(recipients) {
+				return nil, fmt.Errorf("no recipient %d, there are %d", i, len(recipients))
 			}
+			indices = append(indices, i)
+			continue
+		}
 
-			// Print the signed transaction
-			fmt.Printf("Signed transaction: %x\n", txBytes)
-			return nil
-		},
+		var found bool
+		for i, recipient := range recipients {
+			if recipient.GetAddress() == ref {
+				indices = append(indices, i)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("%s is not a recipient of this send", ref)
+		}
 	}
+	return indices, nil
+}
 
-	cmd.Flags().Int32Var(&feeRate, "fee-rate", -1, "Fee rate in sat/vB")
-	cmd.Flags().String("network", "", "Network to use (mainnet, testnet, signet, regtest)")
+// parseCoinControl turns the --utxo, --exclude and --coin-selection flags into a coin control.
+// Without a strategy the coin_selection config value is used.
+func parseCoinControl(only, excluded []string, coinSelection string) (wallet.CoinControl, error) {
+	var coinControl wallet.CoinControl
+	if coinSelection == "" {
+		coinSelection = viper.GetString("coin_selection")
+	}
+	strategy, err := wallet.ParseCoinSelectionStrategy(coinSelection)
+	if err != nil {
+		return coinControl, err
+	}
+	coinControl.Strategy = strategy
 
-	return cmd
+	for _, arg := range only {
+		outpoint, err := wallet.ParseOutpoint(arg)
+		if err != nil {
+			return coinControl, err
+		}
+		coinControl.Only = append(coinControl.Only, outpoint)
+	}
+	for _, arg := range excluded {
+		outpoint, err := wallet.ParseOutpoint(arg)
+		if err != nil {
+			return coinControl, err
+		}
+		coinControl.Exclude = append(coinControl.Exclude, outpoint)
+	}
+	return coinControl, nil
 }
 
 func extractRecipientFromPositionalArg(s string) (*wallet.RecipientImpl, error) {
@@ -96,3 +294,20 @@ func extractRecipientFromPositionalArg(s string) (*wallet.RecipientImpl, error)
 		Amount:  amount,
 	}, nil
 }
+
+// printInputs lists the coins a transaction spends
+func printInputs(walletData *wallet.WalletData, outpoints []string) {
+	fmt.Println("Inputs:")
+	for _, outpoint := range outpoints {
+		for _, u := range walletData.UTXOs {
+			if wallet.FormatOutpoint(u.Txid, u.Vout) != outpoint {
+				continue
+			}
+			label := ""
+			if u.Label != nil {
+				label = " (" + walletData.LabelName(u.Label.M) + ")"
+			}
+			fmt.Printf("  %s  %d sats%s\n", outpoint, u.Amount, label)
+		}
+	}
+}
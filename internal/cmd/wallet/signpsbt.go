@@ -0,0 +1,92 @@
+package wallet
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/setavenger/blindbit-wallet-cli/pkg/wallet"
+	"github.com/spf13/cobra"
+)
+
+func NewSignPsbtCmd() *cobra.Command {
+	var yes bool
+
+	cmd := &cobra.Command{
+		Use:   "sign-psbt <psbt>",
+		Short: "Sign a PSBT created by a watch-only wallet",
+		Long: `Sign the unsigned PSBT printed by 'wallet send' of a watch-only wallet with this wallet's spend secret.
+The PSBT is given as base64, use - to read it from stdin (needs --yes, there is no prompt then).
+Every input carries its silent payment tweak, the coins don't have to be synced in this wallet.
+
+Before signing, every output is printed with its address and amount, outputs to this wallet's change address
+are marked. PSBTs whose outputs exceed the inputs or that pay more than ` + wallet.MaxFeeRate.String() + ` are refused.
+Inputs that don't belong to this wallet are refused. Nothing is saved, broadcast the printed transaction.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			encoded := args[0]
+			if encoded == "-" {
+				if !yes {
+					return fmt.Errorf("reading the psbt from stdin needs --yes, the confirmation can't be asked")
+				}
+				input, err := io.ReadAll(os.Stdin)
+				if err != nil {
+					return fmt.Errorf("failed to read psbt: %w", err)
+				}
+				encoded = string(input)
+			}
+
+			packet, err := psbt.NewFromRawBytes(strings.NewReader(strings.TrimSpace(encoded)), true)
+			if err != nil {
+				return fmt.Errorf("failed to decode psbt: %w", err)
+			}
+
+			handle, err := loadWallet(wallet.LockShared)
+			if err != nil {
+				return err
+			}
+			defer handle.close()
+
+			outputs, fee, feeRate, err := handle.Data.Wallet.InspectUnsignedTx(packet)
+			if err != nil {
+				return fmt.Errorf("refusing to sign psbt: %w", err)
+			}
+
+			fmt.Println("Outputs:")
+			for _, out := range outputs {
+				change := ""
+				if out.Change {
+					change = " (change)"
+				}
+				fmt.Printf("  %s  %d sats%s\n", out.Address, out.Amount, change)
+			}
+			fmt.Printf("Inputs: %d, fee: %d sats (%s)\n", len(packet.Inputs), fee, feeRate)
+
+			if !yes && !confirm("Sign this transaction?") {
+				fmt.Println("Operation cancelled.")
+				return nil
+			}
+
+			tx, err := handle.Data.Wallet.SignUnsignedTx(packet)
+			if err != nil {
+				return fmt.Errorf("failed to sign psbt: %w", err)
+			}
+
+			var buf bytes.Buffer
+			if err := tx.Serialize(&buf); err != nil {
+				return fmt.Errorf("failed to serialize transaction: %w", err)
+			}
+
+			fmt.Printf("Signed transaction: %x\n", buf.Bytes())
+			fmt.Println("Txid:", tx.TxHash())
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&yes, "yes", false, "Sign without asking for confirmation")
+
+	return cmd
+}
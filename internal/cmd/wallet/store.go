@@ -0,0 +1,236 @@
+package wallet
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/setavenger/blindbit-wallet-cli/pkg/storage"
+	"github.com/setavenger/blindbit-wallet-cli/pkg/wallet"
+	"github.com/spf13/viper"
+	"golang.org/x/term"
+)
+
+// passwordEnv can hold the wallet password for non-interactive use (e.g. cron jobs)
+const passwordEnv = "BLINDBIT_WALLET_PASSWORD"
+
+// walletHandle ties loaded wallet data to the storage and password it came from,
+// so that changes are written back the same way they were read.
+type walletHandle struct {
+	store    *storage.Storage
+	lock     *wallet.Lock
+	password string // empty for plaintext wallets
+	Data     *wallet.WalletData
+}
+
+// SelectWallet makes the named wallet the active one for this invocation.
+// The name and the wallet directory are stored in viper's "wallet" and "walletdir" keys.
+func SelectWallet(name string) error {
+	if name == "" {
+		name = storage.DefaultWalletName
+	}
+
+	dir, err := storage.WalletDir(viper.GetString("datadir"), name)
+	if err != nil {
+		return err
+	}
+
+	viper.Set("wallet", name)
+	viper.Set("walletdir", dir)
+	return nil
+}
+
+// openStorage returns the storage of the active wallet (see --wallet)
+func openStorage() (*storage.Storage, error) {
+	store, err := storage.New(viper.GetString("walletdir"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open storage: %w", err)
+	}
+	store.SetMaxBackups(viper.GetInt("max_backups"))
+	return store, nil
+}
+
+// lockWallet takes the advisory lock on the directory of the active wallet.
+// Use wallet.LockShared for read-only commands and wallet.LockExclusive for anything that writes.
+func lockWallet(mode wallet.LockMode) (*wallet.Lock, error) {
+	return wallet.AcquireLock(viper.GetString("walletdir"), mode, viper.GetDuration("lock_timeout"))
+}
+
+// loadWallet loads the wallet, asking for the password if the file is encrypted.
+// The wallet stays locked with the given mode until close is called.
+func loadWallet(mode wallet.LockMode) (*walletHandle, error) {
+	store, err := openStorage()
+	if err != nil {
+		return nil, err
+	}
+
+	password, err := readWalletPassword(store)
+	if err != nil {
+		return nil, err
+	}
+
+	// only lock after the password prompt, so a slow typist doesn't block a cron sync
+	lock, err := lockWallet(mode)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := store.LoadWallet(password)
+	if err != nil {
+		lock.Release()
+		return nil, fmt.Errorf("failed to load wallet: %w", err)
+	}
+
+	return &walletHandle{
+		store:    store,
+		lock:     lock,
+		password: password,
+		Data:     data,
+	}, nil
+}
+
+// readWalletPassword asks for the password of an existing wallet.
+// Returns an empty password for plaintext wallets.
+func readWalletPassword(store *storage.Storage) (string, error) {
+	if !store.WalletExists() {
+		return "", fmt.Errorf("no wallet %q found at %s, see 'wallet list'", viper.GetString("wallet"), store.WalletPath())
+	}
+
+	encrypted, err := store.IsEncrypted()
+	if err != nil {
+		return "", err
+	}
+
+	if !encrypted {
+		fmt.Fprintln(os.Stderr, "Warning: wallet file is not encrypted, run 'wallet encrypt' to protect it")
+		return "", nil
+	}
+
+	return readPassword("Enter wallet password: ")
+}
+
+// close releases the wallet lock
+func (h *walletHandle) close() {
+	h.lock.Release()
+}
+
+// relock releases the current lock, takes a new one with the given mode and reloads the wallet data,
+// which may have been changed by other invocations in between. Used by long running commands
+// that should not block others while they wait, the password is not asked again.
+func (h *walletHandle) relock(mode wallet.LockMode) error {
+	h.lock.Release()
+
+	lock, err := lockWallet(mode)
+	if err != nil {
+		return err
+	}
+	h.lock = lock
+
+	data, err := h.store.LoadWallet(h.password)
+	if err != nil {
+		return fmt.Errorf("failed to load wallet: %w", err)
+	}
+	h.Data = data
+	return nil
+}
+
+// unlock releases the lock while keeping the handle usable for relock
+func (h *walletHandle) unlock() {
+	h.lock.Release()
+	h.lock = nil
+}
+
+// save writes the wallet data back, encrypted unless it was loaded from a plaintext file
+func (h *walletHandle) save() error {
+	if h.password == "" {
+		return h.store.SaveWalletPlaintext(h.Data)
+	}
+	return h.store.SaveWallet(h.Data, h.password)
+}
+
+// readPassword reads a password from the terminal without echo.
+// If BLINDBIT_WALLET_PASSWORD is set it is used instead.
+func readPassword(prompt string) (string, error) {
+	if pw, ok := os.LookupEnv(passwordEnv); ok {
+		return pw, nil
+	}
+	return promptSecret(prompt)
+}
+
+// promptSecret reads a secret from the terminal without echo, always interactive
+func promptSecret(prompt string) (string, error) {
+	fmt.Print(prompt)
+	bytePassword, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Println() // Add newline after password input
+	if err != nil {
+		return "", fmt.Errorf("failed to read password: %w", err)
+	}
+	return string(bytePassword), nil
+}
+
+// readNewPassword asks for a new password twice and makes sure both match.
+// Always interactive, the environment variable is not consulted.
+func readNewPassword() (string, error) {
+	return readNewSecret("wallet password")
+}
+
+// readNewSecret asks for a new non-empty secret twice and makes sure both match
+func readNewSecret(what string) (string, error) {
+	first, err := promptSecret(fmt.Sprintf("Enter new %s: ", what))
+	if err != nil {
+		return "", err
+	}
+	if len(first) == 0 {
+		return "", storage.ErrEmptyPassword
+	}
+
+	second, err := promptSecret(fmt.Sprintf("Repeat new %s: ", what))
+	if err != nil {
+		return "", err
+	}
+
+	if first != second {
+		return "", fmt.Errorf("%ss do not match", what)
+	}
+	return first, nil
+}
+
+// passphraseWarning is shown whenever a BIP39 passphrase is entered
+const passphraseWarning = `Note: the passphrase is not stored anywhere. A different passphrase produces a
+completely different wallet, the mnemonic alone will NOT restore this wallet.`
+
+// readBIP39Passphrase reads the optional BIP39 passphrase (25th word) from the terminal.
+// With repeat set the passphrase has to be entered twice.
+func readBIP39Passphrase(repeat bool) (string, error) {
+	fmt.Println(passphraseWarning)
+	fmt.Print("Enter BIP39 passphrase: ")
+	first, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Println()
+	if err != nil {
+		return "", fmt.Errorf("failed to read passphrase: %w", err)
+	}
+	if len(first) == 0 {
+		return "", fmt.Errorf("passphrase must not be empty when --passphrase is set")
+	}
+
+	if repeat {
+		fmt.Print("Repeat BIP39 passphrase: ")
+		second, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Println()
+		if err != nil {
+			return "", fmt.Errorf("failed to read passphrase: %w", err)
+		}
+		if string(first) != string(second) {
+			return "", fmt.Errorf("passphrases do not match")
+		}
+	}
+
+	return string(first), nil
+}
+
+// confirm asks a y/N question and returns true only on an explicit yes
+func confirm(question string) bool {
+	fmt.Print(question + " (y/N): ")
+	var response string
+	fmt.Scanln(&response)
+	return response == "y" || response == "Y"
+}
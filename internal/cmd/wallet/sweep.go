@@ -0,0 +1,39 @@
+package wallet
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func NewSweepCmd() *cobra.Command {
+	var (
+		feeRate  feeRateFlag
+		only     []string
+		excluded []string
+		dryRun   bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "sweep <address>",
+		Short: "Send all coins to one address",
+		Long: `Spend all confirmed, unfrozen coins (or exactly the coins given with --utxo) to a single regular or
+silent payment address. The fee is subtracted from the amount, no change output is created.
+Same as 'wallet send <address>:max', watch-only wallets print an unsigned PSBT.`,
+		Example: `  blindbit-wallet-cli wallet sweep sp1q... --fee-rate 5
+  blindbit-wallet-cli wallet sweep bc1q... --fee-rate 5 --utxo <txid:vout> --utxo <txid:vout>`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			coinControl, err := parseCoinControl(only, excluded, "")
+			if err != nil {
+				return err
+			}
+			return runSend(cmd, []string{args[0] + ":max"}, feeRate.FeeRate, coinControl, nil, dryRun)
+		},
+	}
+
+	cmd.Flags().Var(&feeRate, "fee-rate", "Fee rate in sat/vB, fractions like 1.5 are allowed")
+	cmd.Flags().StringArrayVar(&only, "utxo", nil, "Sweep exactly this coin (txid:vout), can be repeated")
+	cmd.Flags().StringArrayVar(&excluded, "exclude", nil, "Keep this coin (txid:vout), can be repeated")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Only print the coin selection, nothing is signed or saved")
+
+	return cmd
+}
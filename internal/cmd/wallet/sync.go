@@ -2,113 +2,152 @@ package wallet
 
 import (
 	"bytes"
+	"context"
 	"encoding/hex"
 	"fmt"
-	"strings"
 
-	client "github.com/setavenger/blindbit-wallet-cli/internal/client"
-	"github.com/setavenger/blindbit-wallet-cli/internal/config"
 	scanclient "github.com/setavenger/blindbit-wallet-cli/pkg/clients/blindbitscan"
 	"github.com/setavenger/blindbit-wallet-cli/pkg/wallet"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 )
 
 var syncCmd = &cobra.Command{
 	Use:   "sync",
 	Short: "Sync with blindbit-scan",
-	Long:  `Fetch UTXOs and labels from blindbit-scan and update the local wallet data.`,
+	Long: `Fetch UTXOs and labels from blindbit-scan and update the local wallet data.
+Before trusting the UTXOs the address derived by the daemon is compared with the wallet address,
+a daemon configured with other keys aborts the sync unless --skip-daemon-check is set.
+The daemon only finds payments to labels 1 to wallet.label_count of its own config, the sync
+reminds you of the value the wallet's labels need.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		datadir := viper.GetString("datadir")
-		w, err := wallet.Load(datadir)
+		handle, err := loadWallet(wallet.LockExclusive)
 		if err != nil {
-			return fmt.Errorf("failed to load wallet: %w", err)
+			return err
 		}
+		defer handle.close()
 
-		// Create Tor client if enabled
-		var torClient *client.TorClient
-		if viper.GetBool("use_tor") {
-			torClient, err = client.NewTorClient(&config.Config{
-				UseTor:     viper.GetBool("use_tor"),
-				TorHost:    viper.GetString("tor_host"),
-				TorPort:    viper.GetInt("tor_port"),
-				TorControl: viper.GetString("tor_control"),
-			})
-			if err != nil {
-				return fmt.Errorf("failed to create Tor client: %w", err)
-			}
-			defer torClient.Close()
+		client, closeClient, err := newScanClient()
+		if err != nil {
+			return err
 		}
+		defer closeClient()
 
-		// Create blindbit-scan client
-		host := viper.GetString("scan_host")
-		port := viper.GetInt("scan_port")
-		username := viper.GetString("scan_user")
-		password := viper.GetString("scan_pass")
+		skipDaemonCheck, _ := cmd.Flags().GetBool("skip-daemon-check")
+		return syncWallet(cmd.Context(), handle, client, skipDaemonCheck)
+	},
+}
 
-		// Use the host directly if it already includes a protocol
-		baseURL := host
-		if !strings.HasPrefix(host, "http://") && !strings.HasPrefix(host, "https://") {
-			baseURL = fmt.Sprintf("http://%s:%d", host, port)
-		}
-		client := scanclient.NewClient(baseURL, username, password, torClient)
+func init() {
+	syncCmd.Flags().Bool("skip-daemon-check", false, "Sync even if the daemon's keys do not match the wallet")
+}
 
-		// Get current height
-		height, err := client.GetCurrentHeight()
-		if err != nil {
-			return fmt.Errorf("failed to get current height: %w", err)
+// syncWallet fetches the UTXOs from blindbit-scan, merges them into the wallet data and saves it.
+// The handle has to hold the exclusive lock.
+func syncWallet(ctx context.Context, handle *walletHandle, client *scanclient.Client, skipDaemonCheck bool) error {
+	w := &handle.Data.Wallet
+
+	if !skipDaemonCheck {
+		if err := checkDaemonKeys(client, *w); err != nil {
+			return err
 		}
+	}
+
+	// Get current height
+	height, err := client.GetCurrentHeight()
+	if err != nil {
+		return fmt.Errorf("failed to get current height: %w", err)
+	}
 
-		// Get UTXOs
-		scanUtxos, err := client.GetUTXOs(cmd.Context())
+	// Get UTXOs
+	scanUtxos, err := client.GetUTXOs(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get UTXOs: %w", err)
+	}
+
+	// Convert UTXOs to our format and verify ownership
+	utxos := make([]wallet.UTXO, 0, len(scanUtxos))
+	for _, u := range scanUtxos {
+		// Verify UTXO ownership by checking if we can derive the public key (works for watch-only wallets too)
+		derivedPubKey, err := w.OutputPubKey(u.PrivKeyTweak)
 		if err != nil {
-			return fmt.Errorf("failed to get UTXOs: %w", err)
+			fmt.Printf("Warning: Skipping UTXO %s (vout %d) - ownership verification failed: %v\n",
+				hex.EncodeToString(u.Txid[:]), u.Vout, err)
+			continue
 		}
 
-		// Convert UTXOs to our format and verify ownership
-		utxos := make([]wallet.UTXO, 0, len(scanUtxos))
-		for _, u := range scanUtxos {
-			// Verify UTXO ownership by checking if we can derive the public key
-			derivedPubKey, err := wallet.DerivePublicKey(w.SpendSecret, u.PrivKeyTweak)
-			if err != nil {
-				fmt.Printf("Warning: Skipping UTXO %s (vout %d) - ownership verification failed: %v\n",
-					hex.EncodeToString(u.Txid[:]), u.Vout, err)
-				continue
-			}
-
-			// Compare derived public key with UTXO's public key (X-only comparison)
-			derivedPubKeyBytes := derivedPubKey.SerializeCompressed()
-			if !bytes.Equal(derivedPubKeyBytes[1:], u.PubKey[:]) {
-				fmt.Printf("Warning: Skipping UTXO %s (vout %d) - public key mismatch\n",
-					hex.EncodeToString(u.Txid[:]), u.Vout)
-				fmt.Printf("Derived pubkey: %x\n", derivedPubKeyBytes[1:])
-				fmt.Printf("UTXO pubkey: %x\n", u.PubKey[:])
-				continue
-			}
-
-			utxos = append(utxos, wallet.UTXO(*u))
+		// Compare derived public key with UTXO's public key (X-only comparison)
+		derivedPubKeyBytes := derivedPubKey.SerializeCompressed()
+		if !bytes.Equal(derivedPubKeyBytes[1:], u.PubKey[:]) {
+			fmt.Printf("Warning: Skipping UTXO %s (vout %d) - public key mismatch\n",
+				hex.EncodeToString(u.Txid[:]), u.Vout)
+			fmt.Printf("Derived pubkey: %x\n", derivedPubKeyBytes[1:])
+			fmt.Printf("UTXO pubkey: %x\n", u.PubKey[:])
+			continue
 		}
 
-		// Update wallet data
-		data := &wallet.WalletData{
-			Wallet:     *w,
-			UTXOs:      utxos,
-			LastHeight: int64(height),
-		}
+		utxos = append(utxos, wallet.UTXO(*u))
+	}
 
-		// Save updated wallet data
-		if err := wallet.Save(datadir, data); err != nil {
-			return fmt.Errorf("failed to save wallet data: %w", err)
-		}
+	// Merge into the local state, keeping labels and spends the daemon has not seen yet
+	diff := handle.Data.MergeUTXOs(utxos)
+	resolved := handle.Data.ReconcilePending()
+	handle.Data.RecordReceived()
+	handle.Data.LastHeight = int64(height)
 
-		fmt.Println("Wallet synced successfully!")
-		fmt.Printf("Current height: %d\n", height)
-		fmt.Printf("Found %d UTXOs\n", len(utxos))
-		if len(utxos) != len(scanUtxos) {
-			fmt.Printf("Warning: %d UTXOs were skipped due to ownership verification failures\n",
-				len(scanUtxos)-len(utxos))
-		}
+	// Save updated wallet data
+	if err := handle.save(); err != nil {
+		return fmt.Errorf("failed to save wallet data: %w", err)
+	}
 
-		return nil
-	},
+	fmt.Println("Wallet synced successfully!")
+	fmt.Printf("Current height: %d\n", height)
+	fmt.Printf("Found %d UTXOs\n", len(utxos))
+	if len(utxos) != len(scanUtxos) {
+		fmt.Printf("Warning: %d UTXOs were skipped due to ownership verification failures\n",
+			len(scanUtxos)-len(utxos))
+	}
+	printSyncDiff(diff)
+	for _, pending := range resolved {
+		fmt.Println("Pending transaction resolved:", pending.Txid)
+	}
+	if len(handle.Data.PendingTxs) > 0 {
+		fmt.Printf("%d transactions still pending\n", len(handle.Data.PendingTxs))
+	}
+	if highest := highestLabel(handle.Data); highest > defaultDaemonLabelCount {
+		fmt.Printf("Note: payments to labels above the daemon's wallet.label_count are not found, the wallet's labels need at least %d.\n", highest)
+	}
+
+	return nil
+}
+
+func printSyncDiff(diff wallet.SyncDiff) {
+	if diff.Empty() {
+		fmt.Println("No changes since the last sync.")
+		return
+	}
+
+	for _, section := range []struct {
+		name  string
+		utxos []wallet.UTXO
+	}{
+		{"New", diff.New},
+		{"Confirmed", diff.Confirmed},
+		{"Spent", diff.Spent},
+		{"Vanished", diff.Vanished},
+	} {
+		if len(section.utxos) == 0 {
+			continue
+		}
+		var total uint64
+		for _, u := range section.utxos {
+			total += u.Amount
+		}
+		fmt.Printf("%s: %d UTXOs, %d sats\n", section.name, len(section.utxos), total)
+		for _, u := range section.utxos {
+			fmt.Printf("  %s:%d  %d sats\n", hex.EncodeToString(u.Txid[:]), u.Vout, u.Amount)
+		}
+	}
+	if len(diff.Vanished) > 0 {
+		fmt.Println("Vanished UTXOs are no longer reported by the daemon (reorg or changed keys) and were removed.")
+	}
 }
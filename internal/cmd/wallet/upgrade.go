@@ -0,0 +1,163 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/setavenger/blindbit-wallet-cli/pkg/wallet"
+	"github.com/spf13/cobra"
+)
+
+// maxDiffValueLen limits how much of a changed value is printed by 'wallet upgrade'
+const maxDiffValueLen = 80
+
+func NewUpgradeCmd() *cobra.Command {
+	var dryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "upgrade",
+		Short: "Upgrade the wallet file to the current schema version",
+		Long: `Older wallet files are upgraded automatically in memory when they are loaded and written
+in the new format on the next save. This command performs the upgrade explicitly.
+The original file is kept as a backup (see 'wallet backups list').
+Use --dry-run to only show what would change.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := openStorage()
+			if err != nil {
+				return err
+			}
+
+			password, err := readWalletPassword(store)
+			if err != nil {
+				return err
+			}
+
+			mode := wallet.LockExclusive
+			if dryRun {
+				mode = wallet.LockShared
+			}
+			lock, err := lockWallet(mode)
+			if err != nil {
+				return err
+			}
+			defer lock.Release()
+
+			raw, err := store.LoadWalletJSON(password)
+			if err != nil {
+				return fmt.Errorf("failed to load wallet: %w", err)
+			}
+
+			version, err := wallet.DataVersion(raw)
+			if err != nil {
+				return err
+			}
+
+			upgraded, applied, err := wallet.UpgradeData(raw)
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("Wallet data version: %d (current: %d)\n", version, wallet.CurrentVersion)
+			if len(applied) == 0 {
+				fmt.Println("Wallet data is up to date.")
+				return nil
+			}
+
+			fmt.Println("\nMigrations:")
+			for _, m := range applied {
+				fmt.Printf("  v%d -> v%d: %s\n", m.From, m.From+1, m.Description)
+			}
+
+			changes, err := diffTopLevel(raw, upgraded)
+			if err != nil {
+				return err
+			}
+			fmt.Println("\nChanges:")
+			for _, c := range changes {
+				fmt.Println("  " + c)
+			}
+
+			if dryRun {
+				fmt.Println("\nDry run, nothing was written.")
+				return nil
+			}
+
+			data, err := store.LoadWallet(password)
+			if err != nil {
+				return fmt.Errorf("failed to load wallet: %w", err)
+			}
+
+			handle := &walletHandle{store: store, password: password, Data: data}
+			if err := handle.save(); err != nil {
+				return fmt.Errorf("failed to save wallet: %w", err)
+			}
+
+			fmt.Println("\nWallet upgraded successfully!")
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Only show what would change")
+
+	return cmd
+}
+
+// diffTopLevel describes added, removed and changed top level keys between two wallet JSON documents
+func diffTopLevel(before, after []byte) ([]string, error) {
+	var a, b map[string]json.RawMessage
+	if err := json.Unmarshal(before, &a); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(after, &b); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]struct{})
+	for k := range a {
+		keys[k] = struct{}{}
+	}
+	for k := range b {
+		keys[k] = struct{}{}
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	var changes []string
+	for _, k := range sorted {
+		oldValue, inOld := a[k]
+		newValue, inNew := b[k]
+		switch {
+		case !inOld:
+			changes = append(changes, fmt.Sprintf("+ %s: %s", k, shorten(newValue)))
+		case !inNew:
+			changes = append(changes, fmt.Sprintf("- %s", k))
+		case !jsonEqual(oldValue, newValue):
+			changes = append(changes, fmt.Sprintf("~ %s: %s -> %s", k, shorten(oldValue), shorten(newValue)))
+		}
+	}
+	return changes, nil
+}
+
+func jsonEqual(a, b json.RawMessage) bool {
+	var bufA, bufB bytes.Buffer
+	if json.Compact(&bufA, a) != nil || json.Compact(&bufB, b) != nil {
+		return bytes.Equal(a, b)
+	}
+	return bytes.Equal(bufA.Bytes(), bufB.Bytes())
+}
+
+func shorten(v json.RawMessage) string {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, v); err != nil {
+		buf.Write(v)
+	}
+	if buf.Len() > maxDiffValueLen {
+		return buf.String()[:maxDiffValueLen] + "..."
+	}
+	return buf.String()
+}
@@ -10,7 +10,6 @@ import (
 	scanwallet "github.com/setavenger/blindbit-scan/pkg/wallet"
 	"github.com/setavenger/blindbit-wallet-cli/pkg/wallet"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 )
 
 var utxosCmd = &cobra.Command{
@@ -25,11 +24,12 @@ func init() {
 }
 
 func runUtxos(cmd *cobra.Command, args []string) error {
-	datadir := viper.GetString("datadir")
-	walletData, err := wallet.LoadData(datadir)
+	handle, err := loadWallet(wallet.LockShared)
 	if err != nil {
-		return fmt.Errorf("failed to load wallet data: %w", err)
+		return err
 	}
+	defer handle.close()
+	walletData := handle.Data
 
 	state, _ := cmd.Flags().GetString("state")
 	var filteredUtxos []wallet.UTXO
@@ -62,7 +62,7 @@ func runUtxos(cmd *cobra.Command, args []string) error {
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
 	defer w.Flush()
 
-	fmt.Fprintln(w, "TXID\tVOUT\tAMOUNT\tTIMESTAMP\tSTATE\tLABEL")
+	fmt.Fprintln(w, "TXID\tVOUT\tAMOUNT\tTIMESTAMP\tSTATE\tLABEL\tSPENT BY")
 	for _, utxo := range filteredUtxos {
 		state := "unspent"
 		switch utxo.State {
@@ -74,21 +74,31 @@ func runUtxos(cmd *cobra.Command, args []string) error {
 			state = "unconfirmed_spent"
 		}
 
+		if walletData.IsFrozen(utxo) {
+			state += " (frozen)"
+		}
+
 		label := ""
 		if utxo.Label != nil {
-			label = fmt.Sprintf("M=%d", utxo.Label.M)
+			label = walletData.LabelName(utxo.Label.M)
+		}
+
+		spentBy := ""
+		if pending := walletData.PendingTx(wallet.FormatOutpoint(utxo.Txid, utxo.Vout)); pending != nil {
+			spentBy = pending.Txid + " (pending)"
 		}
 
 		// Convert Unix timestamp to human-readable time
 		timestamp := time.Unix(int64(utxo.Timestamp), 0).Format("2006-01-02 15:04:05")
 
-		fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\t%s\n",
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\t%s\t%s\n",
 			hex.EncodeToString(utxo.Txid[:]),
 			utxo.Vout,
 			utxo.Amount,
 			timestamp,
 			state,
-			label)
+			label,
+			spentBy)
 	}
 
 	return nil
@@ -22,6 +22,28 @@ func NewCommand() *cobra.Command {
 	WalletCmd.AddCommand(utxosCmd)
 	WalletCmd.AddCommand(addressCmd)
 	WalletCmd.AddCommand(NewSendCmd())
+	WalletCmd.AddCommand(encryptCmd)
+	WalletCmd.AddCommand(decryptCmd)
+	WalletCmd.AddCommand(changePasswordCmd)
+	WalletCmd.AddCommand(NewMigrateDerivationCmd())
+	WalletCmd.AddCommand(backupsCmd)
+	WalletCmd.AddCommand(NewUpgradeCmd())
+	WalletCmd.AddCommand(listCmd)
+	WalletCmd.AddCommand(createCmd)
+	WalletCmd.AddCommand(renameCmd)
+	WalletCmd.AddCommand(NewBackupCmd())
+	WalletCmd.AddCommand(NewRestoreCmd())
+	WalletCmd.AddCommand(NewRegisterCmd())
+	WalletCmd.AddCommand(NewRescanCmd())
+	WalletCmd.AddCommand(NewCheckDaemonCmd())
+	WalletCmd.AddCommand(NewAbandonCmd())
+	WalletCmd.AddCommand(NewHistoryCmd())
+	WalletCmd.AddCommand(NewBalanceCmd())
+	WalletCmd.AddCommand(NewLabelCmd())
+	WalletCmd.AddCommand(NewFreezeCmd())
+	WalletCmd.AddCommand(NewUnfreezeCmd())
+	WalletCmd.AddCommand(NewSweepCmd())
+	WalletCmd.AddCommand(NewSignPsbtCmd())
 
 	return WalletCmd
 }
@@ -0,0 +1,100 @@
+package wallet
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"text/tabwriter"
+
+	"github.com/setavenger/blindbit-wallet-cli/pkg/storage"
+	"github.com/setavenger/blindbit-wallet-cli/pkg/wallet"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	listCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List the wallets in the datadir",
+		Long:  `List all wallets in the datadir. The active wallet (--wallet or default_wallet) is marked with '*'.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			datadir := viper.GetString("datadir")
+
+			names, err := storage.ListWallets(datadir)
+			if err != nil {
+				return err
+			}
+			if len(names) == 0 {
+				fmt.Println("No wallets found. Create one with 'wallet new' or 'wallet create --name <name>'.")
+				return nil
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			defer w.Flush()
+
+			fmt.Fprintln(w, "\tNAME\tENCRYPTED\tPATH")
+			for _, name := range names {
+				dir, err := storage.WalletDir(datadir, name)
+				if err != nil {
+					return err
+				}
+				store, err := storage.New(dir)
+				if err != nil {
+					return fmt.Errorf("failed to open storage: %w", err)
+				}
+				encrypted, err := store.IsEncrypted()
+				if err != nil {
+					return err
+				}
+
+				active := ""
+				if name == viper.GetString("wallet") {
+					active = "*"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%t\t%s\n", active, name, encrypted, store.WalletPath())
+			}
+			return nil
+		},
+	}
+
+	renameCmd = &cobra.Command{
+		Use:   "rename <old-name> <new-name>",
+		Short: "Rename a wallet",
+		Long: `Rename a wallet including its backups. Renaming the default wallet moves it out of the datadir
+into <datadir>/wallets/<new-name>. Update default_wallet in blindbit.toml if it points to the old name.`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			datadir := viper.GetString("datadir")
+			oldName, newName := args[0], args[1]
+
+			oldDir, err := storage.WalletDir(datadir, oldName)
+			if err != nil {
+				return err
+			}
+
+			names, err := storage.ListWallets(datadir)
+			if err != nil {
+				return err
+			}
+			if !slices.Contains(names, oldName) {
+				return fmt.Errorf("no wallet named %q, see 'wallet list'", oldName)
+			}
+
+			lock, err := wallet.AcquireLock(oldDir, wallet.LockExclusive, viper.GetDuration("lock_timeout"))
+			if err != nil {
+				return err
+			}
+			defer lock.Release()
+
+			if err := storage.RenameWallet(datadir, oldName, newName); err != nil {
+				return err
+			}
+
+			fmt.Printf("Wallet %q renamed to %q.\n", oldName, newName)
+			if viper.GetString("default_wallet") == oldName {
+				fmt.Printf("Note: default_wallet is %q, set default_wallet = %q in blindbit.toml to keep using this wallet without --wallet.\n", oldName, newName)
+			}
+			return nil
+		},
+	}
+)
@@ -1,14 +1,29 @@
 package config
 
+import "time"
+
 // Config holds all configuration details.
 type Config struct {
 	DataDir string `mapstructure:"datadir"`
 
+	// Wallet is the active wallet, DefaultWallet is used if --wallet is not given
+	Wallet        string `mapstructure:"wallet"`
+	DefaultWallet string `mapstructure:"default_wallet"`
+
 	ScanHost string `mapstructure:"scan_host"`
 	ScanPort int    `mapstructure:"scan_port"`
 	ScanUser string `mapstructure:"scan_user"`
 	ScanPass string `mapstructure:"scan_pass"`
 
+	// Number of previous wallet file versions to keep
+	MaxBackups int `mapstructure:"max_backups"`
+
+	// How long to wait for the wallet lock held by another invocation
+	LockTimeout time.Duration `mapstructure:"lock_timeout"`
+
+	// Coin selection strategy used when send is not given --coin-selection
+	CoinSelection string `mapstructure:"coin_selection"`
+
 	// Tor configuration
 	UseTor     bool   `mapstructure:"use_tor"`
 	TorHost    string `mapstructure:"tor_host"`
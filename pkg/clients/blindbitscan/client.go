@@ -75,7 +75,7 @@ type heightResponse struct {
 	Height uint64 `json:"height"`
 }
 
-// addressResponse mirrors the JSON response from GET /address and PUT /silentpaymentkeys.
+// addressResponse mirrors the JSON response from GET /address and PUT /new-keys.
 type addressResponse struct {
 	Address string `json:"address"`
 }
@@ -85,7 +85,7 @@ type RescanReq struct {
 	Height uint64 `json:"height"`
 }
 
-// SetupReq is used for PUT /silentpaymentkeys.
+// SetupReq is used for PUT /new-keys.
 type SetupReq struct {
 	ScanSecret  string `json:"secret_sec"`
 	SpendPublic string `json:"spend_pub"`
@@ -8,9 +8,9 @@ import (
 	"net/http"
 )
 
-// PutSilentPaymentKeys calls the PUT /silentpaymentkeys endpoint.
+// PutSilentPaymentKeys calls the PUT /new-keys endpoint and returns the address the daemon derives.
 func (c *Client) PutSilentPaymentKeys(req SetupReq) (string, error) {
-	url := fmt.Sprintf("%s/silentpaymentkeys", c.baseURL)
+	url := fmt.Sprintf("%s/new-keys", c.baseURL)
 	data, err := json.Marshal(req)
 	if err != nil {
 		return "", err
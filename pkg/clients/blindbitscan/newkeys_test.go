@@ -0,0 +1,48 @@
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_PutSilentPaymentKeys(t *testing.T) {
+	var received map[string]any
+	mux := http.NewServeMux()
+	mux.HandleFunc("PUT /new-keys", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		_ = json.NewEncoder(w).Encode(map[string]string{"address": "tsp1qexample"})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	c := NewClient(server.URL, "", "", nil)
+
+	address, err := c.PutSilentPaymentKeys(SetupReq{
+		ScanSecret:  "aa",
+		SpendPublic: "02bb",
+		BirthHeight: 840_000,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "tsp1qexample", address)
+	assert.Equal(t, map[string]any{
+		"secret_sec":   "aa",
+		"spend_pub":    "02bb",
+		"birth_height": float64(840_000),
+	}, received)
+}
+
+func TestClient_PutSilentPaymentKeysError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	c := NewClient(server.URL, "", "", nil)
+
+	_, err := c.PutSilentPaymentKeys(SetupReq{ScanSecret: "aa", SpendPublic: "02bb"})
+	assert.Error(t, err)
+}
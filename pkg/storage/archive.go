@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/setavenger/blindbit-wallet-cli/pkg/wallet"
+)
+
+const (
+	// ArchiveVersion is the format version of full-state backup archives
+	ArchiveVersion = 1
+
+	archiveKind = "blindbit-wallet-backup"
+)
+
+// Archive is a full-state wallet backup: everything the wallet knows beyond its keys plus the relevant config.
+// It is stored encrypted with a backup passphrase in the same format as the wallet file.
+type Archive struct {
+	Kind      string    `json:"kind"`
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	// WalletName is the name the wallet had in its datadir
+	WalletName string `json:"wallet_name"`
+	// Wallet is the full WalletData, it carries its own schema version and is migrated on restore
+	Wallet json.RawMessage `json:"wallet"`
+	// Config holds the settings needed to use the wallet again (scan daemon, tor, network)
+	Config map[string]any `json:"config,omitempty"`
+}
+
+// NewArchive creates an archive of the wallet data
+func NewArchive(walletName string, data *wallet.WalletData, config map[string]any) (*Archive, error) {
+	data.Version = wallet.CurrentVersion
+	walletJSON, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal wallet data: %w", err)
+	}
+
+	return &Archive{
+		Kind:       archiveKind,
+		Version:    ArchiveVersion,
+		CreatedAt:  time.Now().UTC(),
+		WalletName: walletName,
+		Wallet:     walletJSON,
+		Config:     config,
+	}, nil
+}
+
+// WalletData decodes the archived wallet, upgrading older schema versions
+func (a *Archive) WalletData() (*wallet.WalletData, error) {
+	data, _, err := wallet.DecodeData(a.Wallet)
+	return data, err
+}
+
+// WriteArchive encrypts the archive with the passphrase and writes it to path
+func WriteArchive(path string, a *Archive, passphrase string) error {
+	if passphrase == "" {
+		return ErrEmptyPassword
+	}
+
+	payload, err := json.Marshal(a)
+	if err != nil {
+		return fmt.Errorf("failed to marshal archive: %w", err)
+	}
+
+	encrypted, err := seal(payload, passphrase, DefaultKDFParams)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt archive: %w", err)
+	}
+
+	return writeFileAtomic(path, encrypted, 0600)
+}
+
+// ReadArchive reads and decrypts an archive written by WriteArchive
+func ReadArchive(path, passphrase string) (*Archive, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive: %w", err)
+	}
+	if !hasHeader(raw) {
+		return nil, fmt.Errorf("%s is not a wallet backup archive", path)
+	}
+
+	payload, err := open(raw, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt archive (wrong passphrase?): %w", err)
+	}
+
+	var a Archive
+	if err := json.Unmarshal(payload, &a); err != nil || a.Kind != archiveKind {
+		return nil, fmt.Errorf("%s is not a wallet backup archive", path)
+	}
+	if a.Version > ArchiveVersion {
+		return nil, fmt.Errorf("archive version %d is newer than supported version %d, please upgrade blindbit-wallet-cli", a.Version, ArchiveVersion)
+	}
+
+	return &a, nil
+}
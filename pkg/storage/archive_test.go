@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/setavenger/blindbit-wallet-cli/pkg/wallet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArchive_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backup.bbw")
+
+	data := &wallet.WalletData{LastHeight: 840_000, Wallet: wallet.Wallet{Network: wallet.NetworkSignet}}
+	a, err := NewArchive("treasury", data, map[string]any{"scan_host": "scan.local", "scan_port": 8080})
+	require.NoError(t, err)
+	require.NoError(t, WriteArchive(path, a, "backup pass"))
+
+	_, err = ReadArchive(path, "wrong")
+	assert.Error(t, err)
+
+	read, err := ReadArchive(path, "backup pass")
+	require.NoError(t, err)
+	assert.Equal(t, "treasury", read.WalletName)
+	assert.Equal(t, "scan.local", read.Config["scan_host"])
+
+	restored, err := read.WalletData()
+	require.NoError(t, err)
+	assert.Equal(t, int64(840_000), restored.LastHeight)
+	assert.Equal(t, wallet.NetworkSignet, restored.Wallet.Network)
+}
+
+func TestArchive_RejectsWalletFile(t *testing.T) {
+	s, err := NewWithParams(t.TempDir(), testKDFParams)
+	require.NoError(t, err)
+	require.NoError(t, s.SaveWallet(&wallet.WalletData{}, "pw"))
+
+	// an encrypted wallet.json decrypts fine but is not an archive
+	_, err = ReadArchive(s.WalletPath(), "pw")
+	assert.ErrorContains(t, err, "not a wallet backup archive")
+
+	plain := filepath.Join(t.TempDir(), "plain.json")
+	require.NoError(t, os.WriteFile(plain, []byte(`{}`), 0600))
+	_, err = ReadArchive(plain, "pw")
+	assert.Error(t, err)
+}
@@ -0,0 +1,280 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	backupDirName    = "backups"
+	backupPrefix     = "wallet-"
+	backupSuffix     = ".json"
+	backupTimeFormat = "20060102T150405.000000000Z"
+
+	// DefaultMaxBackups is the number of previous wallet file versions kept in the backup dir
+	DefaultMaxBackups = 10
+)
+
+// Backup is a previous version of the wallet file.
+// Tagged backups (e.g. taken before a schema migration) are rotated like all others.
+type Backup struct {
+	Name string
+	Path string
+	Time time.Time
+	Tag  string
+	Size int64
+}
+
+// SetMaxBackups sets how many previous versions are kept. 0 disables backups.
+func (s *Storage) SetMaxBackups(n int) {
+	if n < 0 {
+		n = 0
+	}
+	s.maxBackups = n
+}
+
+// writeWalletFile replaces the wallet file atomically.
+// The current file is copied into the backup dir first, encrypted with password if it is plaintext.
+func (s *Storage) writeWalletFile(data []byte, password string) error {
+	if err := s.backupCurrent(password); err != nil {
+		return fmt.Errorf("failed to back up wallet file: %w", err)
+	}
+
+	if err := writeFileAtomic(s.WalletPath(), data, 0600); err != nil {
+		return fmt.Errorf("failed to write wallet file: %w", err)
+	}
+	s.backupTag = ""
+
+	return s.pruneBackups()
+}
+
+// backupCurrent copies the current wallet file (if any) into the backup dir.
+// A plaintext file is encrypted with password first, unless password is empty.
+// The copy is tagged if LoadWallet found an older schema, writes happen under the exclusive lock only.
+func (s *Storage) backupCurrent(password string) error {
+	if s.maxBackups == 0 {
+		return nil
+	}
+
+	current, err := os.ReadFile(s.WalletPath())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if password != "" && isPlaintext(current) {
+		current, err = seal(current, password, s.params)
+		if err != nil {
+			return err
+		}
+	}
+
+	return s.writeBackup(current, s.backupTag)
+}
+
+// ReencryptBackups encrypts all backups with newPassword, so none stays readable in plaintext
+// or with oldPassword. Backups that open with neither password are removed,
+// they are left over from an even older password. Returns the number of removed backups.
+func (s *Storage) ReencryptBackups(oldPassword, newPassword string) (int, error) {
+	if newPassword == "" {
+		return 0, ErrEmptyPassword
+	}
+
+	backups, err := s.ListBackups()
+	if err != nil {
+		return 0, err
+	}
+
+	var removed int
+	for _, b := range backups {
+		data, err := os.ReadFile(b.Path)
+		if err != nil {
+			return removed, fmt.Errorf("failed to read backup: %w", err)
+		}
+
+		plaintext := data
+		if !isPlaintext(data) {
+			plaintext, err = open(data, oldPassword)
+			if err != nil {
+				if _, err := open(data, newPassword); err == nil {
+					// already written with the new password
+					continue
+				}
+				if err := os.Remove(b.Path); err != nil {
+					return removed, fmt.Errorf("failed to remove backup: %w", err)
+				}
+				removed++
+				continue
+			}
+		}
+
+		encrypted, err := seal(plaintext, newPassword, s.params)
+		if err != nil {
+			return removed, fmt.Errorf("failed to encrypt backup: %w", err)
+		}
+		if err := writeFileAtomic(b.Path, encrypted, 0600); err != nil {
+			return removed, fmt.Errorf("failed to write backup: %w", err)
+		}
+	}
+
+	return removed, nil
+}
+
+// writeBackup stores data as a new backup with an optional tag
+func (s *Storage) writeBackup(data []byte, tag string) error {
+	backupDir := filepath.Join(s.baseDir, backupDirName)
+	if err := os.MkdirAll(backupDir, 0700); err != nil {
+		return err
+	}
+
+	name := backupPrefix + time.Now().UTC().Format(backupTimeFormat)
+	if tag != "" {
+		name += "-" + tag
+	}
+	name += backupSuffix
+
+	return writeFileAtomic(filepath.Join(backupDir, name), data, 0600)
+}
+
+// pruneBackups removes the oldest backups beyond maxBackups.
+// Existing backups are left alone if backups are disabled.
+func (s *Storage) pruneBackups() error {
+	if s.maxBackups == 0 {
+		return nil
+	}
+
+	backups, err := s.ListBackups()
+	if err != nil {
+		return err
+	}
+
+	// ListBackups returns newest first
+	for i, b := range backups {
+		if i < s.maxBackups {
+			continue
+		}
+		if err := os.Remove(b.Path); err != nil {
+			return fmt.Errorf("failed to remove old backup: %w", err)
+		}
+	}
+	return nil
+}
+
+// ListBackups returns the available backups, newest first
+func (s *Storage) ListBackups() ([]Backup, error) {
+	entries, err := os.ReadDir(filepath.Join(s.baseDir, backupDirName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup dir: %w", err)
+	}
+
+	var backups []Backup
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, backupPrefix) || !strings.HasSuffix(name, backupSuffix) {
+			continue
+		}
+
+		stamp, tag, _ := strings.Cut(strings.TrimSuffix(strings.TrimPrefix(name, backupPrefix), backupSuffix), "-")
+		ts, err := time.Parse(backupTimeFormat, stamp)
+		if err != nil {
+			// not one of ours
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+
+		backups = append(backups, Backup{
+			Name: name,
+			Path: filepath.Join(s.baseDir, backupDirName, name),
+			Time: ts,
+			Tag:  tag,
+			Size: info.Size(),
+		})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].Time.After(backups[j].Time)
+	})
+
+	return backups, nil
+}
+
+// RestoreBackup replaces the wallet file with the named backup.
+// The replaced version is itself backed up, so a restore can be undone.
+func (s *Storage) RestoreBackup(name string) error {
+	backups, err := s.ListBackups()
+	if err != nil {
+		return err
+	}
+
+	for _, b := range backups {
+		if b.Name != name {
+			continue
+		}
+
+		data, err := os.ReadFile(b.Path)
+		if err != nil {
+			return fmt.Errorf("failed to read backup: %w", err)
+		}
+		if len(data) == 0 {
+			return fmt.Errorf("backup %s is empty", name)
+		}
+
+		return s.writeWalletFile(data, "")
+	}
+
+	return fmt.Errorf("backup %s not found", name)
+}
+
+// writeFileAtomic writes data to a temp file in the same directory, fsyncs it
+// and renames it over path. Readers either see the old or the new content, never a partial write.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) (err error) {
+	dir := filepath.Dir(path)
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if err = tmp.Chmod(perm); err != nil {
+		return err
+	}
+	if _, err = tmp.Write(data); err != nil {
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	// persist the rename itself
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
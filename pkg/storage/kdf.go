@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/argon2"
+)
+
+/*
+Encrypted files start with a small header that records how the key was derived.
+
+	magic (6) | version (1) | kdf (1) | time (4) | memory (4) | threads (1) | salt len (1) | salt
+
+The AES-GCM nonce and ciphertext follow directly after the header.
+The header is passed to GCM as additional data so the parameters can't be tampered with.
+Files without the magic prefix are the legacy format (SHA-256 of the password, nonce || ciphertext).
+*/
+
+const (
+	headerVersion1 byte = 1
+
+	kdfArgon2id byte = 1
+
+	saltLen = 16
+	keyLen  = 32
+
+	// upper bounds so a crafted header can't make opening a file exhaust memory or run for hours
+	maxKDFTime   = 100
+	maxKDFMemory = 4 * 1024 * 1024 // 4 GiB in KiB
+)
+
+var headerMagic = []byte("BBWENC")
+
+// KDFParams are the Argon2id cost parameters used to derive the encryption key.
+type KDFParams struct {
+	Time    uint32 // number of passes
+	Memory  uint32 // memory in KiB
+	Threads uint8
+}
+
+// DefaultKDFParams are used for every new save unless overridden with NewWithParams.
+// Roughly a second on a modern laptop.
+var DefaultKDFParams = KDFParams{
+	Time:    3,
+	Memory:  64 * 1024,
+	Threads: 4,
+}
+
+// Validate checks that the parameters are usable, not trivially weak and not absurdly expensive.
+func (p KDFParams) Validate() error {
+	if p.Time < 1 || p.Time > maxKDFTime {
+		return fmt.Errorf("kdf time must be between 1 and %d", maxKDFTime)
+	}
+	if p.Memory < 8*uint32(p.Threads) || p.Memory < 1024 {
+		return fmt.Errorf("kdf memory must be at least 1024 KiB and 8 KiB per thread")
+	}
+	if p.Memory > maxKDFMemory {
+		return fmt.Errorf("kdf memory must be at most %d KiB", maxKDFMemory)
+	}
+	if p.Threads < 1 {
+		return fmt.Errorf("kdf threads must be at least 1")
+	}
+	return nil
+}
+
+// header is the parsed form of the file header
+type header struct {
+	version byte
+	kdf     byte
+	params  KDFParams
+	salt    []byte
+}
+
+func newHeader(params KDFParams) (*header, error) {
+	salt := make([]byte, saltLen)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+	return &header{
+		version: headerVersion1,
+		kdf:     kdfArgon2id,
+		params:  params,
+		salt:    salt,
+	}, nil
+}
+
+func (h *header) marshal() []byte {
+	var buf bytes.Buffer
+	buf.Write(headerMagic)
+	buf.WriteByte(h.version)
+	buf.WriteByte(h.kdf)
+	binary.Write(&buf, binary.BigEndian, h.params.Time)
+	binary.Write(&buf, binary.BigEndian, h.params.Memory)
+	buf.WriteByte(h.params.Threads)
+	buf.WriteByte(byte(len(h.salt)))
+	buf.Write(h.salt)
+	return buf.Bytes()
+}
+
+// parseHeader reads the header from the front of data.
+// It returns the header, the raw header bytes and the remaining payload.
+func parseHeader(data []byte) (*header, []byte, []byte, error) {
+	const fixedLen = 6 + 1 + 1 + 4 + 4 + 1 + 1
+	if len(data) < fixedLen {
+		return nil, nil, nil, fmt.Errorf("header too short")
+	}
+
+	h := &header{}
+	r := bytes.NewReader(data[len(headerMagic):])
+
+	h.version, _ = r.ReadByte()
+	if h.version != headerVersion1 {
+		return nil, nil, nil, fmt.Errorf("unsupported header version %d", h.version)
+	}
+	h.kdf, _ = r.ReadByte()
+	if h.kdf != kdfArgon2id {
+		return nil, nil, nil, fmt.Errorf("unsupported kdf %d", h.kdf)
+	}
+	binary.Read(r, binary.BigEndian, &h.params.Time)
+	binary.Read(r, binary.BigEndian, &h.params.Memory)
+	h.params.Threads, _ = r.ReadByte()
+
+	n, _ := r.ReadByte()
+	if len(data) < fixedLen+int(n) {
+		return nil, nil, nil, fmt.Errorf("header too short")
+	}
+	h.salt = data[fixedLen : fixedLen+int(n)]
+
+	if err := h.params.Validate(); err != nil {
+		return nil, nil, nil, fmt.Errorf("bad kdf params in header: %w", err)
+	}
+
+	return h, data[:fixedLen+int(n)], data[fixedLen+int(n):], nil
+}
+
+func (h *header) deriveKey(password string) []byte {
+	return argon2.IDKey([]byte(password), h.salt, h.params.Time, h.params.Memory, h.params.Threads, keyLen)
+}
+
+// hasHeader reports whether data is in the versioned format
+func hasHeader(data []byte) bool {
+	return bytes.HasPrefix(data, headerMagic)
+}
+
+// deriveKeyLegacy is the original single SHA-256 derivation.
+// Only used to open files written before the versioned header existed.
+func deriveKeyLegacy(password string) []byte {
+	hash := sha256.Sum256([]byte(password))
+	return hash[:]
+}
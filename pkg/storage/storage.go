@@ -4,7 +4,6 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
-	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -20,77 +19,153 @@ const (
 
 // Storage handles encrypted wallet data storage
 type Storage struct {
-	baseDir string
+	baseDir    string
+	params     KDFParams
+	maxBackups int
+	// backupTag is set by LoadWallet when the file needs a migration, see backupCurrent
+	backupTag string
 }
 
 // New creates a new storage instance
 func New(baseDir string) (*Storage, error) {
+	return NewWithParams(baseDir, DefaultKDFParams)
+}
+
+// NewWithParams creates a new storage instance which derives keys for new saves with the given params.
+// Existing files are always opened with the params stored in their header.
+func NewWithParams(baseDir string, params KDFParams) (*Storage, error) {
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
 	if err := os.MkdirAll(baseDir, 0700); err != nil {
 		return nil, fmt.Errorf("failed to create base directory: %w", err)
 	}
-	return &Storage{baseDir: baseDir}, nil
+	return &Storage{baseDir: baseDir, params: params, maxBackups: DefaultMaxBackups}, nil
+}
+
+// ErrEmptyPassword is returned when trying to encrypt with an empty password
+var ErrEmptyPassword = fmt.Errorf("password must not be empty")
+
+// WalletPath returns the path of the wallet file
+func (s *Storage) WalletPath() string {
+	return filepath.Join(s.baseDir, walletFileName)
 }
 
 // SaveWallet saves the wallet data with encryption
 func (s *Storage) SaveWallet(data *wallet.WalletData, password string) error {
-	// Marshal wallet data
+	if password == "" {
+		return ErrEmptyPassword
+	}
+
+	// Marshal wallet data, always in the current schema
+	data.Version = wallet.CurrentVersion
 	jsonData, err := json.Marshal(data)
 	if err != nil {
 		return fmt.Errorf("failed to marshal wallet data: %w", err)
 	}
 
-	// Generate encryption key from password
-	key := deriveKey(password)
-
-	// Encrypt data
-	encrypted, err := encrypt(key, jsonData)
+	// Encrypt data, always in the current format.
+	// Legacy files are upgraded this way on their next save.
+	encrypted, err := seal(jsonData, password, s.params)
 	if err != nil {
 		return fmt.Errorf("failed to encrypt wallet data: %w", err)
 	}
 
 	// Save to file
-	walletPath := filepath.Join(s.baseDir, walletFileName)
-	if err := os.WriteFile(walletPath, encrypted, 0600); err != nil {
-		return fmt.Errorf("failed to write wallet file: %w", err)
+	return s.writeWalletFile(encrypted, password)
+}
+
+// SaveWalletPlaintext saves the wallet data without encryption.
+// Only meant for wallets the user explicitly decrypted.
+func (s *Storage) SaveWalletPlaintext(data *wallet.WalletData) error {
+	data.Version = wallet.CurrentVersion
+	jsonData, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal wallet data: %w", err)
 	}
 
-	return nil
+	return s.writeWalletFile(jsonData, "")
 }
 
-// LoadWallet loads and decrypts wallet data
+// LoadWallet loads and decrypts wallet data.
+// Plaintext wallet files are loaded as is and the password is ignored.
+// Files with an older schema are upgraded in memory, nothing is written.
+// The next save keeps the original file as a tagged backup.
 func (s *Storage) LoadWallet(password string) (*wallet.WalletData, error) {
+	decrypted, err := s.readWallet(password)
+	if err != nil {
+		return nil, err
+	}
+
+	data, applied, err := wallet.DecodeData(decrypted)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(applied) > 0 {
+		// the next save backs up the pre-migration file under this tag
+		s.backupTag = fmt.Sprintf("pre-v%d", wallet.CurrentVersion)
+	}
+
+	return data, nil
+}
+
+// LoadWalletJSON returns the decrypted wallet JSON exactly as stored, without any migrations
+func (s *Storage) LoadWalletJSON(password string) ([]byte, error) {
+	return s.readWallet(password)
+}
+
+// readWallet returns the decrypted JSON of the wallet file
+func (s *Storage) readWallet(password string) ([]byte, error) {
 	walletPath := filepath.Join(s.baseDir, walletFileName)
-	encrypted, err := os.ReadFile(walletPath)
+	raw, err := os.ReadFile(walletPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read wallet file: %w", err)
 	}
 
-	// Generate decryption key from password
-	key := deriveKey(password)
+	decrypted := raw
+	if !isPlaintext(raw) {
+		decrypted, err = open(raw, password)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt wallet data: %w", err)
+		}
+	}
+
+	return decrypted, nil
+}
 
-	// Decrypt data
-	decrypted, err := decrypt(key, encrypted)
+// seal derives a fresh key with a new random salt and encrypts data behind a versioned header
+func seal(data []byte, password string, params KDFParams) ([]byte, error) {
+	h, err := newHeader(params)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decrypt wallet data: %w", err)
+		return nil, err
 	}
+	headerBytes := h.marshal()
 
-	// Unmarshal wallet data
-	var data wallet.WalletData
-	if err := json.Unmarshal(decrypted, &data); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal wallet data: %w", err)
+	ciphertext, err := encrypt(h.deriveKey(password), data, headerBytes)
+	if err != nil {
+		return nil, err
 	}
 
-	return &data, nil
+	return append(headerBytes, ciphertext...), nil
 }
 
-// deriveKey generates an encryption key from a password
-func deriveKey(password string) []byte {
-	hash := sha256.Sum256([]byte(password))
-	return hash[:]
+// open decrypts data written by seal or by the legacy SHA-256 format
+func open(data []byte, password string) ([]byte, error) {
+	if !hasHeader(data) {
+		return decrypt(deriveKeyLegacy(password), data, nil)
+	}
+
+	h, headerBytes, payload, err := parseHeader(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return decrypt(h.deriveKey(password), payload, headerBytes)
 }
 
 // encrypt encrypts data using AES-256-GCM
-func encrypt(key, data []byte) ([]byte, error) {
+func encrypt(key, data, additionalData []byte) ([]byte, error) {
 	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, err
@@ -106,12 +181,12 @@ func encrypt(key, data []byte) ([]byte, error) {
 		return nil, err
 	}
 
-	ciphertext := gcm.Seal(nonce, nonce, data, nil)
+	ciphertext := gcm.Seal(nonce, nonce, data, additionalData)
 	return ciphertext, nil
 }
 
 // decrypt decrypts data using AES-256-GCM
-func decrypt(key, data []byte) ([]byte, error) {
+func decrypt(key, data, additionalData []byte) ([]byte, error) {
 	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, err
@@ -129,7 +204,7 @@ func decrypt(key, data []byte) ([]byte, error) {
 	nonce := data[:gcm.NonceSize()]
 	ciphertext := data[gcm.NonceSize():]
 
-	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, additionalData)
 	if err != nil {
 		return nil, err
 	}
@@ -137,6 +212,20 @@ func decrypt(key, data []byte) ([]byte, error) {
 	return plaintext, nil
 }
 
+// IsEncrypted reports whether the wallet file on disk is encrypted
+func (s *Storage) IsEncrypted() (bool, error) {
+	raw, err := os.ReadFile(filepath.Join(s.baseDir, walletFileName))
+	if err != nil {
+		return false, fmt.Errorf("failed to read wallet file: %w", err)
+	}
+	return !isPlaintext(raw), nil
+}
+
+// isPlaintext reports whether data is an unencrypted wallet file
+func isPlaintext(data []byte) bool {
+	return !hasHeader(data) && json.Valid(data)
+}
+
 // WalletExists checks if a wallet file exists
 func (s *Storage) WalletExists() bool {
 	walletPath := filepath.Join(s.baseDir, walletFileName)
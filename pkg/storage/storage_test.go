@@ -0,0 +1,237 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/setavenger/blindbit-wallet-cli/pkg/wallet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// cheap params so the tests stay fast
+var testKDFParams = KDFParams{Time: 1, Memory: 1024, Threads: 1}
+
+func TestStorage_SaveLoadRoundTrip(t *testing.T) {
+	s, err := NewWithParams(t.TempDir(), testKDFParams)
+	require.NoError(t, err)
+
+	data := &wallet.WalletData{LastHeight: 840_000}
+	require.NoError(t, s.SaveWallet(data, "correct horse"))
+
+	raw, err := os.ReadFile(filepath.Join(s.baseDir, walletFileName))
+	require.NoError(t, err)
+	assert.True(t, hasHeader(raw))
+
+	loaded, err := s.LoadWallet("correct horse")
+	require.NoError(t, err)
+	assert.Equal(t, int64(840_000), loaded.LastHeight)
+
+	_, err = s.LoadWallet("wrong")
+	assert.Error(t, err)
+}
+
+func TestStorage_SaltIsRandom(t *testing.T) {
+	a, err := seal([]byte("payload"), "pw", testKDFParams)
+	require.NoError(t, err)
+	b, err := seal([]byte("payload"), "pw", testKDFParams)
+	require.NoError(t, err)
+
+	ha, _, _, err := parseHeader(a)
+	require.NoError(t, err)
+	hb, _, _, err := parseHeader(b)
+	require.NoError(t, err)
+	assert.NotEqual(t, ha.salt, hb.salt)
+}
+
+func TestStorage_TamperedHeaderFails(t *testing.T) {
+	blob, err := seal([]byte("payload"), "pw", testKDFParams)
+	require.NoError(t, err)
+
+	// bump the time cost, GCM must reject the modified header
+	blob[len(headerMagic)+5]++
+	_, err = open(blob, "pw")
+	assert.Error(t, err)
+}
+
+func TestStorage_LegacyFormatUpgradesOnSave(t *testing.T) {
+	s, err := NewWithParams(t.TempDir(), testKDFParams)
+	require.NoError(t, err)
+
+	// write a file the way the old SHA-256 only implementation did
+	legacy, err := encrypt(deriveKeyLegacy("pw"), []byte(`{"last_height":5}`), nil)
+	require.NoError(t, err)
+	walletPath := filepath.Join(s.baseDir, walletFileName)
+	require.NoError(t, os.WriteFile(walletPath, legacy, 0600))
+
+	loaded, err := s.LoadWallet("pw")
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), loaded.LastHeight)
+
+	require.NoError(t, s.SaveWallet(loaded, "pw"))
+
+	raw, err := os.ReadFile(walletPath)
+	require.NoError(t, err)
+	assert.True(t, hasHeader(raw))
+
+	loaded, err = s.LoadWallet("pw")
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), loaded.LastHeight)
+}
+
+func TestStorage_BackupsRotateAndRestore(t *testing.T) {
+	s, err := NewWithParams(t.TempDir(), testKDFParams)
+	require.NoError(t, err)
+	s.SetMaxBackups(3)
+
+	for height := int64(1); height <= 5; height++ {
+		require.NoError(t, s.SaveWalletPlaintext(&wallet.WalletData{LastHeight: height}))
+	}
+
+	backups, err := s.ListBackups()
+	require.NoError(t, err)
+	require.Len(t, backups, 3)
+
+	// newest backup holds the version before the current one
+	require.NoError(t, s.RestoreBackup(backups[0].Name))
+	loaded, err := s.LoadWallet("")
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), loaded.LastHeight)
+
+	// the restore backed up height 5 so it can be undone
+	backups, err = s.ListBackups()
+	require.NoError(t, err)
+	require.NoError(t, s.RestoreBackup(backups[0].Name))
+	loaded, err = s.LoadWallet("")
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), loaded.LastHeight)
+
+	assert.Error(t, s.RestoreBackup("wallet-does-not-exist.json"))
+}
+
+func TestStorage_HeaderParamsAboveLimitsFail(t *testing.T) {
+	for _, params := range []KDFParams{
+		{Time: maxKDFTime + 1, Memory: 1024, Threads: 1},
+		{Time: 1, Memory: maxKDFMemory + 1, Threads: 1},
+	} {
+		_, err := NewWithParams(t.TempDir(), params)
+		assert.Error(t, err)
+
+		// a crafted header must be rejected before any key is derived
+		h, err := newHeader(params)
+		require.NoError(t, err)
+		blob := append(h.marshal(), make([]byte, 64)...)
+		_, _, _, err = parseHeader(blob)
+		assert.ErrorContains(t, err, "bad kdf params")
+		_, err = open(blob, "pw")
+		assert.Error(t, err)
+	}
+}
+
+func TestStorage_NoPlaintextBackupsAfterEncrypt(t *testing.T) {
+	s, err := NewWithParams(t.TempDir(), testKDFParams)
+	require.NoError(t, err)
+
+	for height := int64(1); height <= 3; height++ {
+		require.NoError(t, s.SaveWalletPlaintext(&wallet.WalletData{LastHeight: height}))
+	}
+
+	// wallet encrypt: the plaintext file is encrypted before it goes into the backups
+	data, err := s.LoadWallet("")
+	require.NoError(t, err)
+	require.NoError(t, s.SaveWallet(data, "old"))
+	removed, err := s.ReencryptBackups("", "old")
+	require.NoError(t, err)
+	assert.Zero(t, removed)
+
+	backups, err := s.ListBackups()
+	require.NoError(t, err)
+	require.Len(t, backups, 3)
+	for _, b := range backups {
+		raw, err := os.ReadFile(b.Path)
+		require.NoError(t, err)
+		assert.False(t, isPlaintext(raw), b.Name)
+		_, err = open(raw, "old")
+		assert.NoError(t, err, b.Name)
+	}
+
+	// wallet change-password: no backup opens with the old password anymore
+	require.NoError(t, s.SaveWallet(data, "new"))
+	removed, err = s.ReencryptBackups("old", "new")
+	require.NoError(t, err)
+	assert.Zero(t, removed)
+
+	backups, err = s.ListBackups()
+	require.NoError(t, err)
+	require.Len(t, backups, 4)
+	for _, b := range backups {
+		raw, err := os.ReadFile(b.Path)
+		require.NoError(t, err)
+		assert.False(t, isPlaintext(raw), b.Name)
+		_, err = open(raw, "old")
+		assert.Error(t, err, b.Name)
+		_, err = open(raw, "new")
+		assert.NoError(t, err, b.Name)
+	}
+
+	// the restored backup opens with the new password
+	require.NoError(t, s.RestoreBackup(backups[len(backups)-1].Name))
+	loaded, err := s.LoadWallet("new")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), loaded.LastHeight)
+
+	// backups from an even older password are removed
+	stale, err := seal([]byte(`{"last_height":9}`), "older", testKDFParams)
+	require.NoError(t, err)
+	require.NoError(t, s.writeBackup(stale, ""))
+	removed, err = s.ReencryptBackups("new", "newer")
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+}
+
+func TestStorage_MigrationBackupOnlyOnSave(t *testing.T) {
+	s, err := NewWithParams(t.TempDir(), testKDFParams)
+	require.NoError(t, err)
+	s.SetMaxBackups(2)
+
+	// a version 0 file, encrypted
+	v0, err := seal([]byte(`{"last_height":7,"utxos":null}`), "pw", testKDFParams)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(s.WalletPath(), v0, 0600))
+
+	// a read-only load migrates in memory and writes nothing
+	_, err = s.LoadWallet("pw")
+	require.NoError(t, err)
+	reader, err := NewWithParams(s.baseDir, testKDFParams)
+	require.NoError(t, err)
+	_, err = reader.LoadWallet("pw")
+	require.NoError(t, err)
+	backups, err := s.ListBackups()
+	require.NoError(t, err)
+	assert.Empty(t, backups)
+
+	// the save keeps the original file as is, still encrypted, under the migration tag
+	data, err := s.LoadWallet("pw")
+	require.NoError(t, err)
+	require.NoError(t, s.SaveWallet(data, "pw"))
+	backups, err = s.ListBackups()
+	require.NoError(t, err)
+	require.Len(t, backups, 1)
+	assert.Equal(t, fmt.Sprintf("pre-v%d", wallet.CurrentVersion), backups[0].Tag)
+	raw, err := os.ReadFile(backups[0].Path)
+	require.NoError(t, err)
+	assert.Equal(t, v0, raw)
+
+	// later saves are untagged and the tagged backup is rotated out
+	for range 2 {
+		require.NoError(t, s.SaveWallet(data, "pw"))
+	}
+	backups, err = s.ListBackups()
+	require.NoError(t, err)
+	require.Len(t, backups, 2)
+	for _, b := range backups {
+		assert.Empty(t, b.Tag)
+	}
+}
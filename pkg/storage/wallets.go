@@ -0,0 +1,132 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+)
+
+/*
+Named wallets share one datadir. The default wallet lives in the datadir itself
+(where wallet.json always was), every other wallet gets its own directory:
+
+	<datadir>/wallet.json                  default
+	<datadir>/wallets/<name>/wallet.json   named wallets
+
+Each wallet directory has its own backups and lock file.
+*/
+
+const (
+	// DefaultWalletName is the wallet stored directly in the datadir
+	DefaultWalletName = "default"
+
+	walletsDirName = "wallets"
+)
+
+var walletNameRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_-]{0,63}$`)
+
+// ErrWalletNotFound is returned if a named wallet does not exist
+var ErrWalletNotFound = errors.New("wallet not found")
+
+// ValidateWalletName checks that name is usable as a directory name
+func ValidateWalletName(name string) error {
+	if !walletNameRegex.MatchString(name) {
+		return fmt.Errorf("invalid wallet name %q: use up to 64 letters, digits, '-' or '_'", name)
+	}
+	return nil
+}
+
+// WalletDir returns the directory holding the wallet with the given name
+func WalletDir(datadir, name string) (string, error) {
+	if name == "" || name == DefaultWalletName {
+		return datadir, nil
+	}
+	if err := ValidateWalletName(name); err != nil {
+		return "", err
+	}
+	return filepath.Join(datadir, walletsDirName, name), nil
+}
+
+// ListWallets returns the names of all wallets in the datadir, sorted with the default wallet first
+func ListWallets(datadir string) ([]string, error) {
+	var names []string
+	if fileExists(filepath.Join(datadir, walletFileName)) {
+		names = append(names, DefaultWalletName)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(datadir, walletsDirName))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read wallets directory: %w", err)
+	}
+
+	var named []string
+	for _, e := range entries {
+		if !e.IsDir() || ValidateWalletName(e.Name()) != nil {
+			continue
+		}
+		if fileExists(filepath.Join(datadir, walletsDirName, e.Name(), walletFileName)) {
+			named = append(named, e.Name())
+		}
+	}
+	sort.Strings(named)
+
+	return append(names, named...), nil
+}
+
+// RenameWallet moves a wallet including its backups to a new name.
+// The caller has to hold the exclusive lock of the old wallet.
+func RenameWallet(datadir, oldName, newName string) error {
+	oldDir, err := WalletDir(datadir, oldName)
+	if err != nil {
+		return err
+	}
+	newDir, err := WalletDir(datadir, newName)
+	if err != nil {
+		return err
+	}
+	if oldDir == newDir {
+		return fmt.Errorf("wallet is already named %q", newName)
+	}
+
+	if !fileExists(filepath.Join(oldDir, walletFileName)) {
+		return fmt.Errorf("%w: %s", ErrWalletNotFound, oldName)
+	}
+	if fileExists(filepath.Join(newDir, walletFileName)) {
+		return fmt.Errorf("a wallet named %q already exists", newName)
+	}
+
+	// named wallets own their whole directory and can simply be moved
+	if oldDir != datadir && newDir != datadir {
+		if err := os.MkdirAll(filepath.Dir(newDir), 0700); err != nil {
+			return fmt.Errorf("failed to create wallets directory: %w", err)
+		}
+		if err := os.Rename(oldDir, newDir); err != nil {
+			return fmt.Errorf("failed to rename wallet: %w", err)
+		}
+		return nil
+	}
+
+	// the default wallet shares the datadir with the config and other wallets, only move its own files
+	if err := os.MkdirAll(newDir, 0700); err != nil {
+		return fmt.Errorf("failed to create wallet directory: %w", err)
+	}
+	for _, name := range []string{walletFileName, backupDirName} {
+		src := filepath.Join(oldDir, name)
+		if _, err := os.Stat(src); os.IsNotExist(err) {
+			continue
+		}
+		if err := os.Rename(src, filepath.Join(newDir, name)); err != nil {
+			return fmt.Errorf("failed to move %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/setavenger/blindbit-wallet-cli/pkg/wallet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWalletDir(t *testing.T) {
+	datadir := "/data"
+	for _, tc := range []struct {
+		name string
+		dir  string
+		err  bool
+	}{
+		{name: "", dir: datadir},
+		{name: DefaultWalletName, dir: datadir},
+		{name: "treasury", dir: filepath.Join(datadir, walletsDirName, "treasury")},
+		{name: "signet-test_2", dir: filepath.Join(datadir, walletsDirName, "signet-test_2")},
+		{name: "../escape", err: true},
+		{name: "-flag", err: true},
+	} {
+		dir, err := WalletDir(datadir, tc.name)
+		if tc.err {
+			assert.Error(t, err, tc.name)
+			continue
+		}
+		require.NoError(t, err, tc.name)
+		assert.Equal(t, tc.dir, dir)
+	}
+}
+
+func TestListAndRenameWallets(t *testing.T) {
+	datadir := t.TempDir()
+
+	saveAt := func(name string) {
+		dir, err := WalletDir(datadir, name)
+		require.NoError(t, err)
+		s, err := NewWithParams(dir, testKDFParams)
+		require.NoError(t, err)
+		// save twice so there is a backup to move along
+		require.NoError(t, s.SaveWalletPlaintext(&wallet.WalletData{}))
+		require.NoError(t, s.SaveWalletPlaintext(&wallet.WalletData{}))
+	}
+	saveAt(DefaultWalletName)
+	saveAt("signet")
+
+	names, err := ListWallets(datadir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{DefaultWalletName, "signet"}, names)
+
+	// the default wallet moves out of the datadir together with its backups
+	require.NoError(t, RenameWallet(datadir, DefaultWalletName, "treasury"))
+	names, err = ListWallets(datadir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"signet", "treasury"}, names)
+
+	treasury, err := New(filepath.Join(datadir, walletsDirName, "treasury"))
+	require.NoError(t, err)
+	backups, err := treasury.ListBackups()
+	require.NoError(t, err)
+	assert.Len(t, backups, 1)
+
+	assert.Error(t, RenameWallet(datadir, "signet", "treasury"))
+	assert.ErrorIs(t, RenameWallet(datadir, "missing", "other"), ErrWalletNotFound)
+
+	require.NoError(t, RenameWallet(datadir, "signet", "test"))
+	names, err = ListWallets(datadir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"test", "treasury"}, names)
+}
@@ -0,0 +1,72 @@
+package wallet
+
+import (
+	scanwallet "github.com/setavenger/blindbit-scan/pkg/wallet"
+)
+
+// Balance sums the wallet's coins by state, all amounts in sats
+type Balance struct {
+	// Confirmed is spendable now
+	Confirmed uint64
+	// Unconfirmed is incoming but not mined yet
+	Unconfirmed uint64
+	// PendingOut is spent by pending transactions of this wallet
+	PendingOut uint64
+	// PendingChange comes back to us from pending transactions, it is not a coin until the next sync
+	PendingChange uint64
+	// Frozen is the part of Confirmed that coin selection does not use
+	Frozen uint64
+	// ByLabel sums confirmed and unconfirmed coins per label (m), coins to the base address are not included
+	ByLabel map[uint32]uint64
+	// Uneconomical are the confirmed coins that cost at least their value to spend at the fee rate
+	UneconomicalCount int
+	Uneconomical      uint64
+}
+
+// Total is everything the wallet will own once all pending transactions are mined
+func (b Balance) Total() uint64 {
+	return b.Confirmed + b.Unconfirmed + b.PendingChange
+}
+
+// InputFee is the fee to spend one taproot key path input at the fee rate
+func InputFee(feeRate FeeRate) uint64 {
+	return feeRate.Fee(VSize(TrInputWeight))
+}
+
+// Balance computes the balance from the coins' states.
+// With a fee rate of 0 no coins are counted as uneconomical.
+func (d *WalletData) Balance(feeRate FeeRate) Balance {
+	b := Balance{ByLabel: make(map[uint32]uint64)}
+	inputFee := InputFee(feeRate)
+
+	for _, u := range d.UTXOs {
+		switch u.State {
+		case scanwallet.StateUnspent:
+			b.Confirmed += u.Amount
+			if d.IsFrozen(u) {
+				b.Frozen += u.Amount
+			}
+			if !feeRate.IsZero() && u.Amount <= inputFee {
+				b.UneconomicalCount++
+				b.Uneconomical += u.Amount
+			}
+		case scanwallet.StateUnconfirmed:
+			b.Unconfirmed += u.Amount
+		case scanwallet.StateUnconfirmedSpent:
+			b.PendingOut += u.Amount
+			continue
+		default:
+			continue
+		}
+
+		if u.Label != nil {
+			b.ByLabel[u.Label.M] += u.Amount
+		}
+	}
+
+	for _, pending := range d.PendingTxs {
+		b.PendingChange += pending.ChangeAmount
+	}
+
+	return b
+}
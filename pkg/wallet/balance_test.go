@@ -0,0 +1,54 @@
+package wallet
+
+import (
+	"testing"
+
+	scanwallet "github.com/setavenger/blindbit-scan/pkg/wallet"
+	"github.com/setavenger/go-bip352"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWalletData_Balance(t *testing.T) {
+	coin := func(amount uint64, state scanwallet.UTXOState, m *uint32) UTXO {
+		u := UTXO{Amount: amount, State: state}
+		if m != nil {
+			u.Label = &bip352.Label{M: *m}
+		}
+		return u
+	}
+	one := uint32(1)
+
+	d := &WalletData{
+		UTXOs: []UTXO{
+			coin(100_000, scanwallet.StateUnspent, nil),
+			coin(500, scanwallet.StateUnspent, &one),
+			coin(20_000, scanwallet.StateUnconfirmed, &one),
+			coin(30_000, scanwallet.StateUnconfirmedSpent, nil),
+			coin(40_000, scanwallet.StateSpent, &one),
+		},
+		PendingTxs: []PendingTx{{ChangeAmount: 9_000}},
+	}
+
+	// a taproot input is 230 WU, 58 vB rounded up
+	assert.EqualValues(t, 580, InputFee(SatPerVByte(10)))
+
+	for _, tc := range []struct {
+		feeRate           uint32
+		uneconomicalCount int
+		uneconomical      uint64
+	}{
+		{feeRate: 0},
+		{feeRate: 5},
+		{feeRate: 10, uneconomicalCount: 1, uneconomical: 500},
+	} {
+		b := d.Balance(SatPerVByte(uint64(tc.feeRate)))
+		assert.EqualValues(t, 100_500, b.Confirmed)
+		assert.EqualValues(t, 20_000, b.Unconfirmed)
+		assert.EqualValues(t, 30_000, b.PendingOut)
+		assert.EqualValues(t, 9_000, b.PendingChange)
+		assert.EqualValues(t, 129_500, b.Total())
+		assert.Equal(t, map[uint32]uint64{1: 20_500}, b.ByLabel)
+		assert.Equal(t, tc.uneconomicalCount, b.UneconomicalCount, "fee rate %d", tc.feeRate)
+		assert.Equal(t, tc.uneconomical, b.Uneconomical, "fee rate %d", tc.feeRate)
+	}
+}
@@ -0,0 +1,200 @@
+package wallet
+
+import (
+	"slices"
+
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+// bnbMaxTries bounds the branch-and-bound search, the same limit Bitcoin Core uses
+const bnbMaxTries = 100_000
+
+// BnBCoinSelector
+// Branch-and-bound selection for transactions without change, modeled after Bitcoin Core.
+// It searches for a set of coins that pays the recipients and the fee with an excess of at most MaxWaste,
+// the excess goes to the fee instead of a change output.
+// If there is no such set it falls back to the FeeRateCoinSelector which always adds change.
+type BnBCoinSelector struct {
+	OwnedUTXOs      []*UTXO
+	MinChangeAmount uint64
+	Recipients      []Recipient
+	ChainParams     *chaincfg.Params
+	// MaxWaste is the largest excess dropped to fees. 0 means the cost of change:
+	// the fee for the change output plus the fee to spend it later.
+	MaxWaste uint64
+	// SpendAll selects every coin in OwnedUTXOs (coin control), see FeeRateCoinSelector
+	SpendAll bool
+	// SubtractFee skips the search, the recipients pay the fee so there is no excess to drop.
+	// Like Bitcoin Core the accumulative selection is used right away.
+	SubtractFee bool
+}
+
+func NewBnBCoinSelector(
+	utxos []*UTXO,
+	minChangeAmount uint64,
+	recipients []Recipient,
+	chainParams *chaincfg.Params,
+) *BnBCoinSelector {
+	return &BnBCoinSelector{
+		OwnedUTXOs:      utxos,
+		MinChangeAmount: minChangeAmount,
+		Recipients:      recipients,
+		ChainParams:     chainParams,
+	}
+}
+
+// CoinSelect
+// returns the utxos to select and the change amount, the change is 0 if a changeless solution was found.
+func (s *BnBCoinSelector) CoinSelect(
+	feeRate FeeRate,
+) (
+	[]*UTXO, uint64, error,
+) {
+	if feeRate.IsZero() {
+		return nil, 0, ErrInvalidFeeRate
+	}
+
+	outputLens, err := extractPkScriptsFromRecipients(s.Recipients, s.ChainParams)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var target uint64
+	for _, recipient := range s.Recipients {
+		if recipient.GetAmount() == 0 {
+			return nil, 0, ErrRecipientAmountIsZero
+		}
+		target += recipient.GetAmount()
+	}
+
+	maxWaste := s.MaxWaste
+	if maxWaste == 0 {
+		maxWaste = changeOutputFee(feeRate) + InputFee(feeRate)
+	}
+
+	// excess is what is left for the fee beyond the needed fee, negative if the coins are not enough
+	excess := func(sum uint64, numInputs int) int64 {
+		return int64(sum) - int64(target+NeededFeeAbsolutSats(EstimateVSize(numInputs, outputLens), feeRate))
+	}
+
+	if s.SubtractFee {
+		return s.fallback(feeRate)
+	}
+
+	if s.SpendAll {
+		var sum uint64
+		for _, utxo := range s.OwnedUTXOs {
+			sum += utxo.Amount
+		}
+		if e := excess(sum, len(s.OwnedUTXOs)); e >= 0 && uint64(e) <= maxWaste {
+			return s.OwnedUTXOs, 0, nil
+		}
+		return s.fallback(feeRate)
+	}
+
+	if selected, ok := s.search(excess, maxWaste, feeRate); ok {
+		return selected, 0, nil
+	}
+
+	return s.fallback(feeRate)
+}
+
+// search runs the depth first branch-and-bound search over the coins sorted by value, largest first.
+// Returns the set with the smallest excess within maxWaste.
+func (s *BnBCoinSelector) search(
+	excess func(sum uint64, numInputs int) int64,
+	maxWaste uint64,
+	feeRate FeeRate,
+) (
+	[]*UTXO, bool,
+) {
+	// coins that don't pay for their own input only add waste
+	inputFee := InputFee(feeRate)
+	var utxos []*UTXO
+	for _, utxo := range s.OwnedUTXOs {
+		if utxo.Amount > inputFee+1 {
+			utxos = append(utxos, utxo)
+		}
+	}
+	slices.SortStableFunc(utxos, func(a, b *UTXO) int {
+		switch {
+		case a.Amount > b.Amount:
+			return -1
+		case a.Amount < b.Amount:
+			return 1
+		default:
+			return 0
+		}
+	})
+
+	// remaining[i] is an upper bound of what the coins from i on can add to the excess
+	remaining := make([]int64, len(utxos)+1)
+	for i := len(utxos) - 1; i >= 0; i-- {
+		remaining[i] = remaining[i+1] + int64(utxos[i].Amount) - int64(inputFee) + 1
+	}
+
+	var (
+		best       []int
+		bestExcess int64 = -1
+		current    []int
+		sum        uint64
+		tries      int
+	)
+
+	var walk func(i int) bool
+	walk = func(i int) bool {
+		tries++
+		if tries > bnbMaxTries {
+			return false
+		}
+
+		e := excess(sum, len(current))
+		if len(current) > 0 && e > int64(maxWaste) {
+			return true // too much, more coins only add to it
+		}
+		if len(current) > 0 && e >= 0 {
+			if bestExcess < 0 || e < bestExcess {
+				best = slices.Clone(current)
+				bestExcess = e
+			}
+			return e > 0 // an exact match can't be beaten
+		}
+		if i == len(utxos) || e+remaining[i] < 0 {
+			return true // the rest can't reach the target
+		}
+
+		// include utxos[i]
+		current = append(current, i)
+		sum += utxos[i].Amount
+		if !walk(i + 1) {
+			return false
+		}
+		current = current[:len(current)-1]
+		sum -= utxos[i].Amount
+
+		// skip utxos[i], equal amounts would only repeat the same search
+		next := i + 1
+		for next < len(utxos) && utxos[next].Amount == utxos[i].Amount {
+			next++
+		}
+		return walk(next)
+	}
+	walk(0)
+
+	if bestExcess < 0 {
+		return nil, false
+	}
+
+	selected := make([]*UTXO, len(best))
+	for j, i := range best {
+		selected[j] = utxos[i]
+	}
+	return selected, true
+}
+
+func (s *BnBCoinSelector) fallback(feeRate FeeRate) ([]*UTXO, uint64, error) {
+	selector := NewFeeRateCoinSelector(s.OwnedUTXOs, s.MinChangeAmount, s.Recipients, s.ChainParams)
+	selector.SpendAll = s.SpendAll
+	selector.SubtractFee = s.SubtractFee
+	return selector.CoinSelect(feeRate)
+}
@@ -0,0 +1,206 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/stretchr/testify/assert"
+)
+
+var bnbTestCases = []TestCase{
+	{
+		Comment: "single coin matches, change is dropped",
+		Given: struct {
+			Utxos           []*UTXO
+			Recipients      []Recipient
+			FeeRate         uint32
+			MinChangeAmount uint64
+		}{
+			Utxos: []*UTXO{
+				{
+					Amount: 20_000,
+				}, {
+					Amount: 40_000,
+				}, {
+					Amount: 60_000,
+				},
+			},
+			Recipients: []Recipient{
+				&RecipientImpl{
+					Address: "bc1qua7e852suw0p74e2lzxwmk2tw8fd2zuzexc866",
+					Amount:  39_850,
+				},
+			},
+			FeeRate:         1,
+			MinChangeAmount: 5000,
+		},
+		Expected: struct {
+			Change             uint64
+			NumOfSelectedUTXOs int
+			AbsolutFee         uint64
+			Err                error
+		}{Change: 0, NumOfSelectedUTXOs: 1, AbsolutFee: 150, Err: nil},
+	},
+	{
+		Comment: "two coins match, change is dropped",
+		Given: struct {
+			Utxos           []*UTXO
+			Recipients      []Recipient
+			FeeRate         uint32
+			MinChangeAmount uint64
+		}{
+			Utxos: []*UTXO{
+				{
+					Amount: 20_000,
+				}, {
+					Amount: 40_000,
+				}, {
+					Amount: 60_000,
+				},
+			},
+			Recipients: []Recipient{
+				&RecipientImpl{
+					Address: "bc1qua7e852suw0p74e2lzxwmk2tw8fd2zuzexc866",
+					Amount:  79_800,
+				},
+			},
+			FeeRate:         1,
+			MinChangeAmount: 5000,
+		},
+		Expected: struct {
+			Change             uint64
+			NumOfSelectedUTXOs int
+			AbsolutFee         uint64
+			Err                error
+		}{Change: 0, NumOfSelectedUTXOs: 2, AbsolutFee: 200, Err: nil},
+	},
+	{
+		Comment: "excess above the tolerance keeps the change",
+		Given: struct {
+			Utxos           []*UTXO
+			Recipients      []Recipient
+			FeeRate         uint32
+			MinChangeAmount uint64
+		}{
+			Utxos: []*UTXO{
+				{
+					Amount: 20_000,
+				}, {
+					Amount: 40_000,
+				}, {
+					Amount: 60_000,
+				},
+			},
+			Recipients: []Recipient{
+				&RecipientImpl{
+					Address: "bc1qua7e852suw0p74e2lzxwmk2tw8fd2zuzexc866",
+					Amount:  38_900,
+				},
+			},
+			FeeRate:         5,
+			MinChangeAmount: 5000,
+		},
+		Expected: struct {
+			Change             uint64
+			NumOfSelectedUTXOs int
+			AbsolutFee         uint64
+			Err                error
+		}{Change: 20_100, NumOfSelectedUTXOs: 2, AbsolutFee: 1000, Err: nil},
+	},
+	{
+		Comment: "no match falls back to the accumulative selection",
+		Given: struct {
+			Utxos           []*UTXO
+			Recipients      []Recipient
+			FeeRate         uint32
+			MinChangeAmount uint64
+		}{
+			Utxos: []*UTXO{
+				{
+					Amount: 20_000,
+				}, {
+					Amount: 40_000,
+				}, {
+					Amount: 60_000,
+				},
+			},
+			Recipients: []Recipient{
+				&RecipientImpl{
+					Address: "bc1qua7e852suw0p74e2lzxwmk2tw8fd2zuzexc866",
+					Amount:  5000,
+				},
+			},
+			FeeRate:         1,
+			MinChangeAmount: 5000,
+		},
+		Expected: struct {
+			Change             uint64
+			NumOfSelectedUTXOs int
+			AbsolutFee         uint64
+			Err                error
+		}{Change: 14_858, NumOfSelectedUTXOs: 1, AbsolutFee: 142, Err: nil},
+	},
+	{
+		Comment: "not enough funds",
+		Given: struct {
+			Utxos           []*UTXO
+			Recipients      []Recipient
+			FeeRate         uint32
+			MinChangeAmount uint64
+		}{
+			Utxos: []*UTXO{
+				{
+					Amount: 20_000,
+				}, {
+					Amount: 40_000,
+				},
+			},
+			Recipients: []Recipient{
+				&RecipientImpl{
+					Address: "bc1qua7e852suw0p74e2lzxwmk2tw8fd2zuzexc866",
+					Amount:  60_000,
+				},
+			},
+			FeeRate:         1,
+			MinChangeAmount: 5000,
+		},
+		Expected: struct {
+			Change             uint64
+			NumOfSelectedUTXOs int
+			AbsolutFee         uint64
+			Err                error
+		}{Change: 0, NumOfSelectedUTXOs: 0, AbsolutFee: 0, Err: ErrInsufficientFunds},
+	},
+}
+
+func TestBnBCoinSelector_CoinSelect(t *testing.T) {
+	for _, tc := range bnbTestCases {
+		t.Run(tc.Comment, func(t *testing.T) {
+			selector := NewBnBCoinSelector(tc.Given.Utxos, tc.Given.MinChangeAmount, tc.Given.Recipients, &chaincfg.MainNetParams)
+			selectedUTXOs, change, err := selector.CoinSelect(SatPerVByte(uint64(tc.Given.FeeRate)))
+
+			if tc.Expected.Err != nil {
+				assert.Error(t, err)
+				assert.Equal(t, tc.Expected.Err, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.Expected.Change, change)
+			assert.Equal(t, tc.Expected.NumOfSelectedUTXOs, len(selectedUTXOs))
+
+			var sumSelectedAmounts uint64
+			for _, utxo := range selectedUTXOs {
+				sumSelectedAmounts += utxo.Amount
+			}
+
+			var sumRecipientsAmounts uint64
+			for _, recipient := range tc.Given.Recipients {
+				sumRecipientsAmounts += recipient.GetAmount()
+			}
+
+			absoluteFee := sumSelectedAmounts - (sumRecipientsAmounts + change)
+			assert.Equal(t, tc.Expected.AbsolutFee, absoluteFee)
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	scanwallet "github.com/setavenger/blindbit-scan/pkg/wallet"
+)
+
+// CoinControl restricts which coins a send may spend and how they are selected, outpoints are formatted as txid:vout
+type CoinControl struct {
+	// Only spends exactly these coins, all of them
+	Only []string
+	// Exclude never spends these coins
+	Exclude []string
+	// Strategy picks the coin selection algorithm, empty means DefaultCoinSelectionStrategy
+	Strategy CoinSelectionStrategy
+	// SubtractFeeFrom are the indices of the recipients that pay the fee out of their amount instead of on top,
+	// split evenly between them
+	SubtractFeeFrom []int
+}
+
+// ParseOutpoint validates a txid:vout string and returns it in the canonical FormatOutpoint form
+func ParseOutpoint(s string) (string, error) {
+	txidHex, voutStr, ok := strings.Cut(strings.TrimSpace(s), ":")
+	if !ok {
+		return "", fmt.Errorf("bad outpoint %q, use txid:vout", s)
+	}
+
+	txid, err := hex.DecodeString(txidHex)
+	if err != nil || len(txid) != 32 {
+		return "", fmt.Errorf("bad txid in outpoint %q", s)
+	}
+	vout, err := strconv.ParseUint(voutStr, 10, 32)
+	if err != nil {
+		return "", fmt.Errorf("bad vout in outpoint %q", s)
+	}
+
+	return FormatOutpoint([32]byte(txid), uint32(vout)), nil
+}
+
+// findUTXO returns the index of the coin with the outpoint, -1 if the wallet does not have it
+func (d *WalletData) findUTXO(outpoint string) int {
+	return slices.IndexFunc(d.UTXOs, func(u UTXO) bool { return FormatOutpoint(u.Txid, u.Vout) == outpoint })
+}
+
+// IsFrozen reports whether the coin is frozen and never selected automatically
+func (d *WalletData) IsFrozen(u UTXO) bool {
+	return slices.Contains(d.Frozen, FormatOutpoint(u.Txid, u.Vout))
+}
+
+// Freeze keeps a coin out of coin selection until it is unfrozen
+func (d *WalletData) Freeze(outpoint string) error {
+	if d.findUTXO(outpoint) < 0 {
+		return fmt.Errorf("the wallet has no coin %s", outpoint)
+	}
+	if !slices.Contains(d.Frozen, outpoint) {
+		d.Frozen = append(d.Frozen, outpoint)
+	}
+	return nil
+}
+
+// Unfreeze makes a frozen coin selectable again
+func (d *WalletData) Unfreeze(outpoint string) error {
+	i := slices.Index(d.Frozen, outpoint)
+	if i < 0 {
+		return fmt.Errorf("coin %s is not frozen", outpoint)
+	}
+	d.Frozen = slices.Delete(d.Frozen, i, i+1)
+	return nil
+}
+
+// selectableUTXOs returns the coins coin selection may use under the coin control.
+// Coins given in Only must all be confirmed, unspent and not frozen, they are returned in the given order.
+// The pointers point into d.UTXOs.
+func (d *WalletData) selectableUTXOs(cc CoinControl) (scanwallet.UtxoCollection, error) {
+	if len(cc.Only) > 0 {
+		var utxos scanwallet.UtxoCollection
+		for _, outpoint := range cc.Only {
+			i := d.findUTXO(outpoint)
+			switch {
+			case i < 0:
+				return nil, fmt.Errorf("the wallet has no coin %s", outpoint)
+			case d.UTXOs[i].State != scanwallet.StateUnspent:
+				return nil, fmt.Errorf("coin %s is %s, only confirmed unspent coins can be spent", outpoint, d.UTXOs[i].State)
+			case d.IsFrozen(d.UTXOs[i]):
+				return nil, fmt.Errorf("coin %s is frozen, unfreeze it first", outpoint)
+			case slices.Contains(cc.Exclude, outpoint):
+				return nil, fmt.Errorf("coin %s is both selected and excluded", outpoint)
+			case slices.ContainsFunc(utxos, func(u *UTXO) bool { return u == &d.UTXOs[i] }):
+				return nil, fmt.Errorf("coin %s is selected twice", outpoint)
+			}
+			utxos = append(utxos, &d.UTXOs[i])
+		}
+		return utxos, nil
+	}
+
+	var utxos scanwallet.UtxoCollection
+	for _, u := range d.unspentUTXOs() {
+		if d.IsFrozen(*u) || slices.Contains(cc.Exclude, FormatOutpoint(u.Txid, u.Vout)) {
+			continue
+		}
+		utxos = append(utxos, u)
+	}
+	return utxos, nil
+}
@@ -0,0 +1,119 @@
+package wallet
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOutpoint(t *testing.T) {
+	txid := strings.Repeat("ab", 32)
+	for _, tc := range []struct {
+		in   string
+		want string
+		err  bool
+	}{
+		{in: txid + ":1", want: txid + ":1"},
+		{in: " " + strings.ToUpper(txid) + ":0 ", want: txid + ":0"},
+		{in: txid, err: true},
+		{in: txid + ":x", err: true},
+		{in: txid + ":-1", err: true},
+		{in: "abcd:0", err: true},
+	} {
+		got, err := ParseOutpoint(tc.in)
+		if tc.err {
+			assert.Error(t, err, tc.in)
+			continue
+		}
+		require.NoError(t, err, tc.in)
+		assert.Equal(t, tc.want, got)
+	}
+}
+
+func TestSendToRecipients_CoinControl(t *testing.T) {
+	full, _ := testWatchOnlyPair(t)
+	newData := func() *WalletData {
+		d := NewWalletData(full)
+		for _, u := range testOwnedUTXOs(t, full, 50_000, 60_000, 70_000) {
+			d.UTXOs = append(d.UTXOs, *u)
+		}
+		return d
+	}
+	outpoint := func(d *WalletData, i int) string { return FormatOutpoint(d.UTXOs[i].Txid, d.UTXOs[i].Vout) }
+
+	destination, err := btcutil.NewAddressTaproot(make([]byte, 32), &chaincfg.SigNetParams)
+	require.NoError(t, err)
+	send := func(d *WalletData, cc CoinControl) ([]string, error) {
+		_, _, err := SendToRecipients(d, []Recipient{&RecipientImpl{Address: destination.String(), Amount: 20_000}}, SatPerVByte(2), cc)
+		if err != nil {
+			return nil, err
+		}
+		return d.PendingTxs[len(d.PendingTxs)-1].Inputs, nil
+	}
+
+	d := newData()
+	inputs, err := send(d, CoinControl{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{outpoint(d, 0)}, inputs)
+
+	// all chosen coins are spent even though one would do
+	d = newData()
+	inputs, err = send(d, CoinControl{Only: []string{outpoint(d, 2), outpoint(d, 1)}})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{outpoint(d, 1), outpoint(d, 2)}, inputs)
+
+	d = newData()
+	require.NoError(t, d.Freeze(outpoint(d, 0)))
+	inputs, err = send(d, CoinControl{Exclude: []string{outpoint(d, 1)}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{outpoint(d, 2)}, inputs)
+
+	// exclusions only apply to one send, freezing lasts
+	inputs, err = send(d, CoinControl{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{outpoint(d, 1)}, inputs)
+	_, err = send(d, CoinControl{})
+	assert.ErrorIs(t, err, ErrInsufficientFunds)
+	_, err = send(d, CoinControl{Only: []string{outpoint(d, 0)}})
+	assert.ErrorContains(t, err, "frozen")
+	_, err = send(d, CoinControl{Only: []string{outpoint(d, 2)}})
+	assert.ErrorContains(t, err, "unconfirmed_spent")
+
+	require.NoError(t, d.Unfreeze(outpoint(d, 0)))
+	assert.Error(t, d.Unfreeze(outpoint(d, 0)))
+	assert.Error(t, d.Freeze(strings.Repeat("00", 32)+":0"))
+	inputs, err = send(d, CoinControl{Only: []string{outpoint(d, 0)}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{outpoint(d, 0)}, inputs)
+}
+
+func TestSendToRecipients_CoinControlSubDustLeftover(t *testing.T) {
+	full, _ := testWatchOnlyPair(t)
+	destination, err := btcutil.NewAddressTaproot(make([]byte, 32), &chaincfg.SigNetParams)
+	require.NoError(t, err)
+
+	// the chosen coins cover amount and fee with a leftover below the dust limit,
+	// there is no other coin to add so the leftover goes to the fee
+	for _, strategy := range []CoinSelectionStrategy{StrategyBnB, StrategyLargestFirst} {
+		d := NewWalletData(full)
+		for _, u := range testOwnedUTXOs(t, full, 50_000, 60_000, 70_000) {
+			d.UTXOs = append(d.UTXOs, *u)
+		}
+		only := []string{
+			FormatOutpoint(d.UTXOs[0].Txid, d.UTXOs[0].Vout),
+			FormatOutpoint(d.UTXOs[1].Txid, d.UTXOs[1].Vout),
+		}
+
+		recipients := []Recipient{&RecipientImpl{Address: destination.String(), Amount: 109_400}}
+		_, selection, err := SendToRecipients(d, recipients, SatPerVByte(1), CoinControl{Strategy: strategy, Only: only})
+		require.NoError(t, err, strategy)
+		assert.Len(t, selection.Inputs, 2)
+		assert.Zero(t, selection.Change)
+		assert.EqualValues(t, 600, selection.Fee)
+		assert.ElementsMatch(t, only, d.PendingTxs[0].Inputs)
+	}
+}
@@ -1,14 +1,10 @@
 package wallet
 
-/*
-Simplified as we don't expect to produce transactions with more than 252 inputs/outputs.
-Witness data is also very standardised.
-*/
-
 import (
 	"fmt"
-	"math"
+	"slices"
 
+	"github.com/btcsuite/btcd/blockchain"
 	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/txscript"
@@ -16,32 +12,27 @@ import (
 	"github.com/setavenger/go-bip352"
 )
 
-// Length in bytes without witness discount
-
-// See here for explanation of vByte sizes https://bitcoinops.org/en/tools/calc-size/
+// Sizes in weight units (WU), non-witness bytes weigh 4 WU and witness bytes 1 WU.
+// See here for explanation of the sizes https://bitcoinops.org/en/tools/calc-size/
 const (
-	NTxVersionLen                   float64 = 4
-	SegWitMarkerLenAndSegWitFlagLen float64 = 0.5
-	NumInputsLen                    float64 = 1 // todo is a varInt
-
-	TrOutpointTxidLen      float64 = 32
-	TrOutpointVoutLen      float64 = 4
-	TrOutpointSequenceLen  float64 = 4
-	TrEmptyRedeemScriptLen float64 = 1
-	TrInputOutpointLen     float64 = TrOutpointTxidLen + TrOutpointVoutLen + TrEmptyRedeemScriptLen + TrOutpointSequenceLen
-
-	// TrWitnessDataLen already discounted by 0.25 complete length (varInt + actual data)
-	TrWitnessDataLen float64 = 16.25
+	TxVersionWeight           = 4 * blockchain.WitnessScaleFactor
+	SegWitMarkerAndFlagWeight = 2
+	LockTimeWeight            = 4 * blockchain.WitnessScaleFactor
 
-	OutputValueLen float64 = 8
+	// TrInputWeight is a taproot key path input: outpoint (32 + 4), empty scriptSig (1) and sequence (4),
+	// the witness has the item count (1), the signature length (1) and a 64 byte SIGHASH_DEFAULT signature
+	TrInputWeight = (32+4+1+4)*blockchain.WitnessScaleFactor + 1 + 1 + 64
 
-	WitnessCountLen float64 = 1 // todo is a varInt
-
-	NLockTimeLen float64 = 4
+	// OutputValueLen is the 8 byte amount of an output, followed by the scriptPubKey and its length
+	OutputValueLen = 8
 
+	// ScriptPubKeyTaprootLen is used for change and silent payment outputs
 	ScriptPubKeyTaprootLen = 34
 )
 
+// DustLimit is the smallest output amount we create. Conservative for all standard output types.
+const DustLimit uint64 = 546
+
 // Errors
 var (
 	ErrInvalidFeeRate        = fmt.Errorf("invalid fee rate")
@@ -88,6 +79,10 @@ type FeeRateCoinSelector struct {
 	MinChangeAmount uint64
 	Recipients      []Recipient
 	ChainParams     *chaincfg.Params
+	// SpendAll selects every coin in OwnedUTXOs (coin control) instead of stopping once the target is reached
+	SpendAll bool
+	// SubtractFee selects for the recipient amounts only, the recipients pay the fee
+	SubtractFee bool
 }
 
 func NewFeeRateCoinSelector(
@@ -106,41 +101,23 @@ func NewFeeRateCoinSelector(
 
 // CoinSelect
 // returns the utxos to select and the change amount in order to achieve the desired fee rate.
-// NOTE: A change amount is always added.
-// todo don't require a change amount and just increase fee if difference is below a certain threshold
+// NOTE: A change amount is added unless the leftover is below MinChangeAmount, such a leftover is dropped to the fee.
+// BnBCoinSelector looks for a changeless solution first.
 func (s *FeeRateCoinSelector) CoinSelect(
-	feeRate uint32,
+	feeRate FeeRate,
 ) (
 	[]*UTXO, uint64, error,
 ) {
-	// todo should we somehow expose the resulting vBytes for later analysis?
-	// todo reduce complexity in this function
-	if feeRate < 1 {
+	if feeRate.IsZero() {
 		return nil, 0, ErrInvalidFeeRate
 	}
-	// track vBytes of the transaction
-	var vByte float64 // todo make sure we don't face any decimal imprecision
 
-	// OVERHEAD will always be there
-	vByte += NTxVersionLen + SegWitMarkerLenAndSegWitFlagLen + NLockTimeLen
-	vByte += NumInputsLen
-
-	//
 	outputLens, err := extractPkScriptsFromRecipients(s.Recipients, s.ChainParams)
 	if err != nil {
-		fmt.Printf("Error extracting pkScripts: %v\n", err)
-		fmt.Printf("Recipients: %v\n", s.Recipients)
 		return nil, 0, err
 	}
-
-	vByte += float64(wire.VarIntSerializeSize(uint64(len(outputLens))))
-
-	// END OVERHEAD should be 10.5 vByte here
-
-	// add outputs to vByte
-	for _, scriptPubKeyLen := range outputLens {
-		vByte += OutputValueLen + float64(wire.VarIntSerializeSize(uint64(scriptPubKeyLen))) + float64(scriptPubKeyLen)
-	}
+	recipientLens := outputLens
+	outputLens = append(slices.Clone(outputLens), ScriptPubKeyTaprootLen)
 
 	var sumTargetAmount uint64
 	for _, recipient := range s.Recipients {
@@ -153,32 +130,37 @@ func (s *FeeRateCoinSelector) CoinSelect(
 
 	var selectedInputs []*UTXO
 	var sumSelectedInputsAmounts uint64
-	//var potentialVBytes = vByte // tracks a potential increase before actually adding to the main vByte tracking
 
 	for i, utxo := range s.OwnedUTXOs {
-		_ = i
 		// we check that the sum of selected input amounts exceeds the (target Value + fees + (min. change))
 		selectedInputs = append(selectedInputs, utxo)
 		sumSelectedInputsAmounts += utxo.Amount
 
-		if i == 0 {
-			vByte += WitnessCountLen / 4
-			// always add change
-			// todo don't do that
-			vByte += OutputValueLen + float64(wire.VarIntSerializeSize(uint64(ScriptPubKeyTaprootLen))) + float64(ScriptPubKeyTaprootLen)
+		if s.SpendAll && i < len(s.OwnedUTXOs)-1 {
+			continue
 		}
 
-		// outpoint size
-		vByte += TrInputOutpointLen
-		vByte += TrWitnessDataLen
-
-		// todo also check that the fee rate is as we want it
-		if sumSelectedInputsAmounts > sumTargetAmount+NeededFeeAbsolutSats(vByte, feeRate) {
-			if sumSelectedInputsAmounts-(sumTargetAmount+NeededFeeAbsolutSats(vByte, feeRate)) < s.MinChangeAmount {
+		if s.SubtractFee {
+			// the recipients pay the fee, an excess too small for change is dropped to the fee
+			if sumSelectedInputsAmounts < sumTargetAmount {
 				continue
 			}
-			// todo account that change was considered in the vByte tx size
-			return selectedInputs, sumSelectedInputsAmounts - (sumTargetAmount + NeededFeeAbsolutSats(vByte, feeRate)), err
+			if excess := sumSelectedInputsAmounts - sumTargetAmount; excess >= s.MinChangeAmount {
+				return selectedInputs, excess, nil
+			}
+			return selectedInputs, 0, nil
+		}
+
+		needed := sumTargetAmount + NeededFeeAbsolutSats(EstimateVSize(len(selectedInputs), outputLens), feeRate)
+		if sumSelectedInputsAmounts > needed && sumSelectedInputsAmounts-needed >= s.MinChangeAmount {
+			return selectedInputs, sumSelectedInputsAmounts - needed, nil
+		}
+
+		// a leftover too small for change is dropped to the fee, another coin would only cost more.
+		// With SpendAll there is no other coin to add anyway.
+		neededWithoutChange := sumTargetAmount + NeededFeeAbsolutSats(EstimateVSize(len(selectedInputs), recipientLens), feeRate)
+		if sumSelectedInputsAmounts >= neededWithoutChange {
+			return selectedInputs, 0, nil
 		}
 	}
 
@@ -223,6 +205,40 @@ func extractPkScriptsFromRecipients(
 	return pkScriptLens, nil
 }
 
-func NeededFeeAbsolutSats(vByte float64, feeRate uint32) uint64 {
-	return uint64(math.Ceil(vByte * float64(feeRate)))
+// OutputWeight is the weight of an output with a scriptPubKey of scriptLen bytes
+func OutputWeight(scriptLen int) int64 {
+	return int64(OutputValueLen+wire.VarIntSerializeSize(uint64(scriptLen))+scriptLen) * blockchain.WitnessScaleFactor
+}
+
+// EstimateWeight returns the weight of a signed transaction spending numInputs taproot key path inputs
+// to outputs with the given scriptPubKey lengths
+func EstimateWeight(numInputs int, outputScriptLens []int) int64 {
+	weight := int64(TxVersionWeight + LockTimeWeight)
+	weight += int64(wire.VarIntSerializeSize(uint64(numInputs))) * blockchain.WitnessScaleFactor
+	weight += int64(wire.VarIntSerializeSize(uint64(len(outputScriptLens)))) * blockchain.WitnessScaleFactor
+
+	for _, scriptPubKeyLen := range outputScriptLens {
+		weight += OutputWeight(scriptPubKeyLen)
+	}
+
+	if numInputs > 0 {
+		weight += SegWitMarkerAndFlagWeight
+		weight += int64(numInputs) * TrInputWeight
+	}
+
+	return weight
+}
+
+// EstimateVSize returns the virtual size of a signed transaction, see EstimateWeight
+func EstimateVSize(numInputs int, outputScriptLens []int) int64 {
+	return VSize(EstimateWeight(numInputs, outputScriptLens))
+}
+
+// VSize converts a weight to vbytes, rounded up like the mempool does
+func VSize(weight int64) int64 {
+	return (weight + blockchain.WitnessScaleFactor - 1) / blockchain.WitnessScaleFactor
+}
+
+func NeededFeeAbsolutSats(vSize int64, feeRate FeeRate) uint64 {
+	return feeRate.Fee(vSize)
 }
@@ -121,7 +121,7 @@ var testCases = []TestCase{
 			NumOfSelectedUTXOs int
 			AbsolutFee         uint64
 			Err                error
-		}{Change: 8007, NumOfSelectedUTXOs: 2, AbsolutFee: 1993, Err: nil},
+		}{Change: 8000, NumOfSelectedUTXOs: 2, AbsolutFee: 2000, Err: nil},
 	},
 	{
 		Comment: "fails because not enough funds",
@@ -220,7 +220,7 @@ func TestFeeRateCoinSelector_CoinSelect(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.Comment, func(t *testing.T) {
 			selector := NewFeeRateCoinSelector(tc.Given.Utxos, tc.Given.MinChangeAmount, tc.Given.Recipients, &chaincfg.MainNetParams)
-			selectedUTXOs, change, err := selector.CoinSelect(tc.Given.FeeRate)
+			selectedUTXOs, change, err := selector.CoinSelect(SatPerVByte(uint64(tc.Given.FeeRate)))
 
 			if tc.Expected.Err != nil {
 				assert.Error(t, err)
@@ -0,0 +1,85 @@
+package wallet
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+
+	"github.com/tyler-smith/go-bip39"
+)
+
+/*
+Early versions of `wallet new` fed the raw BIP39 entropy into hdkeychain.NewMaster
+instead of the PBKDF2 seed. Those wallets can't be restored from their mnemonic
+by any other BIP39 wallet (including `wallet import`).
+The helpers below detect such wallets and produce the corrected keys.
+*/
+
+// ErrUnknownDerivation is returned if the stored keys match neither derivation scheme
+var ErrUnknownDerivation = fmt.Errorf("wallet keys match neither the standard nor the legacy derivation")
+
+// UsesLegacyDerivation reports whether the wallet keys were derived from the raw mnemonic entropy.
+func (w Wallet) UsesLegacyDerivation() (bool, error) {
+	// passphrases were only added after the fix, watch-only wallets have no mnemonic
+	if w.HasPassphrase || w.Mnemonic == "" {
+		return false, nil
+	}
+
+	seedScan, seedSpend, err := deriveKeysFromSeed(bip39.NewSeed(w.Mnemonic, ""), w.Network)
+	if err != nil {
+		return false, err
+	}
+	if bytes.Equal(seedScan[:], w.ScanSecret) && bytes.Equal(seedSpend[:], w.SpendSecret) {
+		return false, nil
+	}
+
+	legacyScan, legacySpend, err := deriveLegacyKeys(w.Mnemonic, w.Network)
+	if err != nil {
+		return false, err
+	}
+	if bytes.Equal(legacyScan[:], w.ScanSecret) && bytes.Equal(legacySpend[:], w.SpendSecret) {
+		return true, nil
+	}
+
+	return false, ErrUnknownDerivation
+}
+
+// CorrectedWallet returns a copy of the wallet with keys derived from the standard BIP39 seed.
+// Metadata like CreatedAt is kept.
+func (w Wallet) CorrectedWallet() (*Wallet, error) {
+	corrected, err := fromMnemonic(w.Mnemonic, "", w.Network)
+	if err != nil {
+		return nil, err
+	}
+	corrected.CreatedAt = w.CreatedAt
+	return corrected, nil
+}
+
+// SwitchKeys returns the wallet data for w, keeping what doesn't depend on the keys: birth height,
+// history, frozen outpoints and the handed-out label numbers and names, re-derived for the new keys.
+// The UTXOs, pending transactions and scan height belong to the old keys and start empty.
+func (d *WalletData) SwitchKeys(w *Wallet) (*WalletData, error) {
+	data := NewWalletData(w)
+	data.BirthHeight = d.BirthHeight
+	data.History = slices.Clone(d.History)
+	data.Frozen = slices.Clone(d.Frozen)
+
+	for _, l := range d.Labels {
+		bip352Label, err := GenerateLabel(*w, l.M)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create label %d: %w", l.M, err)
+		}
+		data.Labels = append(data.Labels, Label{Label: bip352Label, Name: l.Name})
+	}
+
+	return data, nil
+}
+
+// deriveLegacyKeys reproduces the old derivation from the raw entropy
+func deriveLegacyKeys(mnemonic string, network Network) (scanSecret, spendSecret [32]byte, err error) {
+	entropy, err := bip39.EntropyFromMnemonic(mnemonic)
+	if err != nil {
+		return
+	}
+	return deriveKeysFromSeed(entropy, network)
+}
@@ -0,0 +1,106 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+func TestNewAndImportDeriveSameKeys(t *testing.T) {
+	created, err := New("", NetworkSignet)
+	require.NoError(t, err)
+
+	imported, err := Import(created.Mnemonic, "", NetworkSignet)
+	require.NoError(t, err)
+
+	assert.Equal(t, created.ScanSecret, imported.ScanSecret)
+	assert.Equal(t, created.SpendSecret, imported.SpendSecret)
+
+	legacy, err := created.UsesLegacyDerivation()
+	require.NoError(t, err)
+	assert.False(t, legacy)
+}
+
+func TestImportPassphraseChangesKeys(t *testing.T) {
+	plain, err := Import(testMnemonic, "", NetworkMainnet)
+	require.NoError(t, err)
+
+	withPassphrase, err := Import(testMnemonic, "TREZOR", NetworkMainnet)
+	require.NoError(t, err)
+
+	assert.True(t, withPassphrase.HasPassphrase)
+	assert.NotEqual(t, plain.SpendSecret, withPassphrase.SpendSecret)
+	assert.NotEqual(t, plain.ScanSecret, withPassphrase.ScanSecret)
+}
+
+func TestUsesLegacyDerivation(t *testing.T) {
+	scan, spend, err := deriveLegacyKeys(testMnemonic, NetworkMainnet)
+	require.NoError(t, err)
+
+	w := Wallet{
+		Network:     NetworkMainnet,
+		Mnemonic:    testMnemonic,
+		ScanSecret:  scan[:],
+		SpendSecret: spend[:],
+	}
+
+	legacy, err := w.UsesLegacyDerivation()
+	require.NoError(t, err)
+	assert.True(t, legacy)
+
+	corrected, err := w.CorrectedWallet()
+	require.NoError(t, err)
+	assert.NotEqual(t, w.SpendSecret, corrected.SpendSecret)
+
+	legacy, err = corrected.UsesLegacyDerivation()
+	require.NoError(t, err)
+	assert.False(t, legacy)
+
+	w.SpendSecret = corrected.SpendSecret // mixed keys match neither scheme
+	_, err = w.UsesLegacyDerivation()
+	assert.ErrorIs(t, err, ErrUnknownDerivation)
+}
+
+func TestWalletData_SwitchKeys(t *testing.T) {
+	old, err := New("", NetworkSignet)
+	require.NoError(t, err)
+	d := NewWalletData(old)
+	d.BirthHeight = 840_000
+	d.LastHeight = 850_000
+	d.History = []TxRecord{{Txid: "aa"}}
+	d.Frozen = []string{"bb:0"}
+	for _, u := range testOwnedUTXOs(t, old, 10_000) {
+		d.UTXOs = append(d.UTXOs, *u)
+	}
+	d.PendingTxs = []PendingTx{{Txid: "aa"}}
+	_, err = d.CreateLabel("shop")
+	require.NoError(t, err)
+	_, err = d.CreateLabel("exchange")
+	require.NoError(t, err)
+
+	corrected, err := Import(testMnemonic, "", NetworkSignet)
+	require.NoError(t, err)
+	switched, err := d.SwitchKeys(corrected)
+	require.NoError(t, err)
+
+	assert.Equal(t, *corrected, switched.Wallet)
+	assert.EqualValues(t, 840_000, switched.BirthHeight)
+	assert.Equal(t, d.History, switched.History)
+	assert.Equal(t, d.Frozen, switched.Frozen)
+	assert.Empty(t, switched.UTXOs)
+	assert.Empty(t, switched.PendingTxs)
+	assert.Zero(t, switched.LastHeight)
+
+	require.Len(t, switched.Labels, 2)
+	for i, l := range switched.Labels {
+		assert.Equal(t, d.Labels[i].M, l.M)
+		assert.Equal(t, d.Labels[i].Name, l.Name)
+		expected, err := GenerateLabel(*corrected, l.M)
+		require.NoError(t, err)
+		assert.Equal(t, expected.Address, l.Address)
+		assert.NotEqual(t, d.Labels[i].Address, l.Address)
+	}
+}
@@ -0,0 +1,79 @@
+package wallet
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// FeeRate is a fee rate in sat/vB with a precision of 1/1000 sat/vB, fractional rates like 1.5 sat/vB are allowed
+type FeeRate struct {
+	satPerKvB uint64
+}
+
+// SatPerVByte returns a whole sat/vB fee rate
+func SatPerVByte(rate uint64) FeeRate {
+	return FeeRate{satPerKvB: rate * 1000}
+}
+
+// SatPerKvB returns a fee rate given in sats per 1000 vB
+func SatPerKvB(rate uint64) FeeRate {
+	return FeeRate{satPerKvB: rate}
+}
+
+// ParseFeeRate parses a sat/vB rate like "2" or "1.5", at most 3 decimals
+func ParseFeeRate(s string) (FeeRate, error) {
+	whole, frac, _ := strings.Cut(strings.TrimSpace(s), ".")
+	if whole == "" {
+		whole = "0"
+	}
+	if len(frac) > 3 {
+		return FeeRate{}, fmt.Errorf("bad fee rate %q, at most 3 decimals", s)
+	}
+
+	sats, err := strconv.ParseUint(whole, 10, 32)
+	if err != nil {
+		return FeeRate{}, fmt.Errorf("bad fee rate %q", s)
+	}
+	var milliSats uint64
+	if frac != "" {
+		milliSats, err = strconv.ParseUint(frac+strings.Repeat("0", 3-len(frac)), 10, 32)
+		if err != nil {
+			return FeeRate{}, fmt.Errorf("bad fee rate %q", s)
+		}
+	}
+
+	return FeeRate{satPerKvB: sats*1000 + milliSats}, nil
+}
+
+// FeeRateOf is the rate a fee pays for a transaction of vSize
+func FeeRateOf(fee uint64, vSize int64) FeeRate {
+	if vSize <= 0 {
+		return FeeRate{}
+	}
+	return FeeRate{satPerKvB: fee * 1000 / uint64(vSize)}
+}
+
+func (r FeeRate) IsZero() bool {
+	return r.satPerKvB == 0
+}
+
+func (r FeeRate) SatPerKvB() uint64 {
+	return r.satPerKvB
+}
+
+// Fee is the fee for vSize vbytes at the rate, rounded up to full sats
+func (r FeeRate) Fee(vSize int64) uint64 {
+	if vSize <= 0 {
+		return 0
+	}
+	return (uint64(vSize)*r.satPerKvB + 999) / 1000
+}
+
+func (r FeeRate) String() string {
+	s := strconv.FormatUint(r.satPerKvB/1000, 10)
+	if frac := r.satPerKvB % 1000; frac != 0 {
+		s += strings.TrimRight(fmt.Sprintf(".%03d", frac), "0")
+	}
+	return s + " sat/vB"
+}
@@ -0,0 +1,127 @@
+package wallet
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/mempool"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFeeRate(t *testing.T) {
+	for _, tc := range []struct {
+		given     string
+		satPerKvB uint64
+		str       string
+		err       bool
+	}{
+		{given: "2", satPerKvB: 2000, str: "2 sat/vB"},
+		{given: "1.5", satPerKvB: 1500, str: "1.5 sat/vB"},
+		{given: "0.25", satPerKvB: 250, str: "0.25 sat/vB"},
+		{given: ".1", satPerKvB: 100, str: "0.1 sat/vB"},
+		{given: "12.345", satPerKvB: 12345, str: "12.345 sat/vB"},
+		{given: "1.2345", err: true},
+		{given: "-1", err: true},
+		{given: "abc", err: true},
+		{given: "1.x", err: true},
+	} {
+		rate, err := ParseFeeRate(tc.given)
+		if tc.err {
+			assert.Error(t, err, tc.given)
+			continue
+		}
+		require.NoError(t, err, tc.given)
+		assert.Equal(t, tc.satPerKvB, rate.SatPerKvB(), tc.given)
+		assert.Equal(t, tc.str, rate.String(), tc.given)
+	}
+}
+
+func TestFeeRate_Fee(t *testing.T) {
+	// fees are rounded up to full sats
+	assert.EqualValues(t, 200, SatPerVByte(1).Fee(200))
+	assert.EqualValues(t, 300, SatPerKvB(1500).Fee(200))
+	assert.EqualValues(t, 167, SatPerKvB(1500).Fee(111))
+	assert.EqualValues(t, 0, FeeRate{}.Fee(111))
+	assert.Equal(t, SatPerKvB(1504), FeeRateOf(167, 111))
+}
+
+// The estimate must match the size of the signed transaction exactly, also past the one byte varint counts.
+func TestEstimateVSize_MatchesSignedTx(t *testing.T) {
+	full, _ := testWatchOnlyPair(t)
+	regular, err := btcutil.NewAddressTaproot(make([]byte, 32), &chaincfg.SigNetParams)
+	require.NoError(t, err)
+	segwit, err := btcutil.NewAddressWitnessPubKeyHash(make([]byte, 20), &chaincfg.SigNetParams)
+	require.NoError(t, err)
+
+	for _, numInputs := range []int{1, 2, 5, 253} {
+		amounts := make([]uint64, numInputs)
+		for i := range amounts {
+			amounts[i] = 10_000
+		}
+		utxos := testOwnedUTXOs(t, full, amounts...)
+
+		recipients := []Recipient{
+			&RecipientImpl{Address: regular.String(), Amount: 3_000},
+			&RecipientImpl{Address: segwit.String(), Amount: 3_000},
+			&RecipientImpl{Address: full.ChangeAddress(), Amount: 3_000},
+		}
+		outputLens, err := extractPkScriptsFromRecipients(recipients, &chaincfg.SigNetParams)
+		require.NoError(t, err)
+
+		tx, _, err := buildSignedTx(recipients, full.spendableVins(utxos), &chaincfg.SigNetParams)
+		require.NoError(t, err)
+
+		assert.Equal(t, mempool.GetTxVirtualSize(btcutil.NewTx(tx)), EstimateVSize(numInputs, outputLens), "%d inputs", numInputs)
+	}
+}
+
+func TestSendToRecipients_FractionalFeeRate(t *testing.T) {
+	full, _ := testWatchOnlyPair(t)
+	d := NewWalletData(full)
+	for _, u := range testOwnedUTXOs(t, full, 50_000, 50_000) {
+		d.UTXOs = append(d.UTXOs, *u)
+	}
+	destination, err := btcutil.NewAddressTaproot(make([]byte, 32), &chaincfg.SigNetParams)
+	require.NoError(t, err)
+
+	feeRate, err := ParseFeeRate("1.5")
+	require.NoError(t, err)
+	txBytes, _, err := SendToRecipients(d, []Recipient{&RecipientImpl{Address: destination.String(), Amount: 20_000}}, feeRate, CoinControl{})
+	require.NoError(t, err)
+
+	var tx wire.MsgTx
+	require.NoError(t, tx.Deserialize(bytes.NewReader(txBytes)))
+	var sumOutputs uint64
+	for _, out := range tx.TxOut {
+		sumOutputs += uint64(out.Value)
+	}
+	assert.Equal(t, feeRate.Fee(mempool.GetTxVirtualSize(btcutil.NewTx(&tx))), 50_000-sumOutputs)
+}
+
+func TestCheckFeeRate(t *testing.T) {
+	full, _ := testWatchOnlyPair(t)
+	utxos := testOwnedUTXOs(t, full, 10_000)
+	destination, err := btcutil.NewAddressTaproot(make([]byte, 32), &chaincfg.SigNetParams)
+	require.NoError(t, err)
+
+	build := func(fee uint64) *wire.MsgTx {
+		tx, _, err := buildSignedTx(
+			[]Recipient{&RecipientImpl{Address: destination.String(), Amount: 10_000 - fee}},
+			full.spendableVins(utxos),
+			&chaincfg.SigNetParams,
+		)
+		require.NoError(t, err)
+		return tx
+	}
+
+	// one input and one taproot output are 111 vB
+	feeRate := SatPerVByte(2)
+	assert.NoError(t, checkFeeRate(build(222), utxos, feeRate, 0))
+	assert.Error(t, checkFeeRate(build(221), utxos, feeRate, 0))
+	assert.Error(t, checkFeeRate(build(223), utxos, feeRate, 0))
+	assert.NoError(t, checkFeeRate(build(300), utxos, feeRate, 100))
+}
@@ -0,0 +1,135 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"slices"
+	"sort"
+	"time"
+
+	scanwallet "github.com/setavenger/blindbit-scan/pkg/wallet"
+	"github.com/setavenger/go-bip352"
+)
+
+// TxKind classifies a transaction from the wallet's point of view
+type TxKind string
+
+const (
+	TxReceived TxKind = "received"
+	TxSent     TxKind = "sent"
+	// TxSelf is a transaction that only pays the wallet itself, e.g. a consolidation
+	TxSelf TxKind = "self"
+)
+
+// TxRecord is an entry of the transaction log.
+// Sends are recorded when they are created, received coins when a sync learns about them.
+type TxRecord struct {
+	Txid      string    `json:"txid"`
+	Timestamp time.Time `json:"timestamp"`
+	// Spent is the sum of our coins spent by the transaction
+	Spent uint64 `json:"spent"`
+	// Received is the sum of the outputs paying us
+	Received uint64 `json:"received"`
+	// Fee is only known for transactions created by this wallet
+	Fee uint64 `json:"fee,omitempty"`
+	// Inputs and Outputs are our outpoints (txid:vout) on either side of the transaction
+	Inputs  []string `json:"inputs,omitempty"`
+	Outputs []string `json:"outputs,omitempty"`
+	// Labels are the label numbers (m) of the outputs paying us
+	Labels []uint32 `json:"labels,omitempty"`
+}
+
+// Kind classifies the record as received, sent or self-transfer
+func (r TxRecord) Kind() TxKind {
+	switch {
+	case r.Spent == 0:
+		return TxReceived
+	case r.Received+r.Fee == r.Spent:
+		return TxSelf
+	default:
+		return TxSent
+	}
+}
+
+// Net is the change of the wallet balance caused by the transaction, fee included
+func (r TxRecord) Net() int64 {
+	return int64(r.Received) - int64(r.Spent)
+}
+
+// TxPending reports whether a transaction is not confirmed yet:
+// a send not yet reconciled by a sync or a receive with unconfirmed outputs
+func (d *WalletData) TxPending(r TxRecord) bool {
+	for _, pending := range d.PendingTxs {
+		if pending.Txid == r.Txid {
+			return true
+		}
+	}
+	for _, u := range d.UTXOs {
+		if u.State == scanwallet.StateUnconfirmed && slices.Contains(r.Outputs, FormatOutpoint(u.Txid, u.Vout)) {
+			return true
+		}
+	}
+	return false
+}
+
+// txRecord returns the record for txid, creating it if needed
+func (d *WalletData) txRecord(txid string, timestamp time.Time) *TxRecord {
+	for i := range d.History {
+		if d.History[i].Txid == txid {
+			return &d.History[i]
+		}
+	}
+	d.History = append(d.History, TxRecord{Txid: txid, Timestamp: timestamp})
+	return &d.History[len(d.History)-1]
+}
+
+// creditOutput adds an output paying us to its transaction record, unless it is already recorded
+func (r *TxRecord) creditOutput(outpoint string, amount uint64, label *bip352.Label) {
+	if slices.Contains(r.Outputs, outpoint) {
+		return
+	}
+	r.Outputs = append(r.Outputs, outpoint)
+	r.Received += amount
+	if label != nil && !slices.Contains(r.Labels, label.M) {
+		r.Labels = append(r.Labels, label.M)
+	}
+}
+
+// RecordReceived adds all coins of the wallet to the transaction log that are not in it yet.
+// Called after a sync, it also fills the log for wallets created before the log existed.
+func (d *WalletData) RecordReceived() {
+	for _, u := range d.UTXOs {
+		// unconfirmed coins have no block time yet
+		timestamp := time.Now()
+		if u.Timestamp > 0 {
+			timestamp = time.Unix(int64(u.Timestamp), 0)
+		}
+		record := d.txRecord(hex.EncodeToString(u.Txid[:]), timestamp)
+		record.creditOutput(FormatOutpoint(u.Txid, u.Vout), u.Amount, u.Label)
+	}
+
+	sort.SliceStable(d.History, func(i, j int) bool {
+		return d.History[i].Timestamp.Before(d.History[j].Timestamp)
+	})
+}
+
+// recordSend adds a transaction created by this wallet to the log
+func (d *WalletData) recordSend(pending PendingTx, spent []*UTXO, sumOutputs uint64) {
+	record := d.txRecord(pending.Txid, pending.CreatedAt)
+
+	var sumInputs uint64
+	for _, utxo := range spent {
+		sumInputs += utxo.Amount
+	}
+	record.Spent = sumInputs
+	record.Inputs = pending.Inputs
+	record.Fee = sumInputs - sumOutputs
+
+	if pending.Change != "" {
+		record.creditOutput(pending.Change, pending.ChangeAmount, nil)
+	}
+}
+
+// removeTxRecord drops a record from the log, used for abandoned transactions
+func (d *WalletData) removeTxRecord(txid string) {
+	d.History = slices.DeleteFunc(d.History, func(r TxRecord) bool { return r.Txid == txid })
+}
@@ -0,0 +1,62 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/setavenger/go-bip352"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWalletData_History(t *testing.T) {
+	full, _ := testWatchOnlyPair(t)
+	d := NewWalletData(full)
+	for _, u := range testOwnedUTXOs(t, full, 50_000, 30_000) {
+		d.UTXOs = append(d.UTXOs, *u)
+	}
+	d.UTXOs[1].Label = &bip352.Label{M: 3}
+	d.UTXOs[1].Timestamp = 1_700_000_000
+
+	d.RecordReceived()
+	d.RecordReceived()
+	require.Len(t, d.History, 2)
+	for _, r := range d.History {
+		assert.Equal(t, TxReceived, r.Kind())
+	}
+	assert.Equal(t, []uint32{3}, d.History[0].Labels)
+	assert.EqualValues(t, 30_000, d.History[0].Net())
+
+	destination, err := btcutil.NewAddressTaproot(d.UTXOs[0].PubKey[:], &chaincfg.SigNetParams)
+	require.NoError(t, err)
+	_, _, err = SendToRecipients(d, []Recipient{&RecipientImpl{Address: destination.String(), Amount: 20_000}}, SatPerVByte(2), CoinControl{})
+	require.NoError(t, err)
+
+	require.Len(t, d.History, 3)
+	sent := d.History[2]
+	assert.Equal(t, TxSent, sent.Kind())
+	assert.True(t, d.TxPending(sent))
+	assert.NotZero(t, sent.Fee)
+	assert.Equal(t, -int64(20_000+sent.Fee), sent.Net())
+	require.Len(t, sent.Outputs, 1)
+
+	// once a sync learns that the destination pays us too, it is a self-transfer
+	hash, err := chainhash.NewHashFromStr(sent.Txid)
+	require.NoError(t, err)
+	var txid [32]byte
+	copy(txid[:], bip352.ReverseBytesCopy(hash[:]))
+	for vout := uint32(0); vout < 2; vout++ {
+		if FormatOutpoint(txid, vout) != d.PendingTxs[0].Change {
+			d.UTXOs = append(d.UTXOs, UTXO{Txid: txid, Vout: vout, Amount: 20_000})
+		}
+	}
+	d.RecordReceived()
+	assert.Equal(t, TxSelf, d.History[2].Kind())
+	assert.Equal(t, -int64(sent.Fee), d.History[2].Net())
+
+	d.ReconcilePending()
+	require.NoError(t, d.AbandonPending(sent.Txid))
+	assert.Len(t, d.History, 2)
+}
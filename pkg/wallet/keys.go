@@ -23,6 +23,15 @@ var (
 	}
 )
 
+// ChainParams returns the chain parameters for the network
+func (n Network) ChainParams() (*chaincfg.Params, error) {
+	params, ok := networkParams[n]
+	if !ok {
+		return nil, fmt.Errorf("unsupported network: %s", n)
+	}
+	return params, nil
+}
+
 // DeriveKeys derives scan and spend secrets from a mnemonic
 func DeriveKeys(mnemonic string) (scanSecret, spendSecret []byte, err error) {
 	// Validate mnemonic
@@ -119,8 +128,10 @@ func DerivePublicKey(spendSecret []byte, tweak [32]byte) (*btcec.PublicKey, erro
 
 // GetScanOnlyParams returns the scan-only parameters for the wallet
 func (w *Wallet) GetScanOnlyParams() *ScanOnlyParams {
+	spendPub := w.PubKeySpend()
 	return &ScanOnlyParams{
-		ScanSecret: w.ScanSecret,
-		Network:    w.Network,
+		ScanSecret:  w.ScanSecret,
+		SpendPublic: spendPub[:],
+		Network:     w.Network,
 	}
 }
@@ -0,0 +1,101 @@
+package wallet
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ChangeLabelM is the label reserved for change outputs, user labels start at 1
+const ChangeLabelM uint32 = 0
+
+var (
+	ErrLabelNotFound = fmt.Errorf("label not found")
+	ErrLabelExists   = fmt.Errorf("a label with this name already exists")
+)
+
+// validateLabelName rejects names that can't be told apart from a label number
+func validateLabelName(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return fmt.Errorf("label name must not be empty")
+	}
+	if _, err := strconv.ParseUint(name, 10, 32); err == nil {
+		return fmt.Errorf("label name %q must not be a number", name)
+	}
+	return nil
+}
+
+// CreateLabel allocates the next free label number, derives its address and stores it under name
+func (d *WalletData) CreateLabel(name string) (Label, error) {
+	if err := validateLabelName(name); err != nil {
+		return Label{}, err
+	}
+	if _, err := d.FindLabel(name); err == nil {
+		return Label{}, fmt.Errorf("%w: %s", ErrLabelExists, name)
+	}
+
+	m := ChangeLabelM + 1
+	for _, l := range d.Labels {
+		if l.M >= m {
+			m = l.M + 1
+		}
+	}
+
+	bip352Label, err := GenerateLabel(d.Wallet, m)
+	if err != nil {
+		return Label{}, fmt.Errorf("failed to create label %d: %w", m, err)
+	}
+
+	label := Label{Label: bip352Label, Name: name}
+	d.Labels = append(d.Labels, label)
+	return label, nil
+}
+
+// FindLabel looks up a label by name or by its number m
+func (d *WalletData) FindLabel(ref string) (*Label, error) {
+	for i := range d.Labels {
+		if d.Labels[i].Name == ref {
+			return &d.Labels[i], nil
+		}
+	}
+
+	if m, err := strconv.ParseUint(ref, 10, 32); err == nil {
+		for i := range d.Labels {
+			if d.Labels[i].M == uint32(m) {
+				return &d.Labels[i], nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrLabelNotFound, ref)
+}
+
+// RenameLabel gives the label referenced by name or number a new name
+func (d *WalletData) RenameLabel(ref, name string) error {
+	if err := validateLabelName(name); err != nil {
+		return err
+	}
+	if existing, err := d.FindLabel(name); err == nil && existing.Name == name {
+		return fmt.Errorf("%w: %s", ErrLabelExists, name)
+	}
+
+	label, err := d.FindLabel(ref)
+	if err != nil {
+		return err
+	}
+	label.Name = name
+	return nil
+}
+
+// LabelName is the display name of label m: its name, "change" or M=<m> for unnamed labels
+func (d *WalletData) LabelName(m uint32) string {
+	for _, l := range d.Labels {
+		if l.M == m && l.Name != "" {
+			return l.Name
+		}
+	}
+	if m == ChangeLabelM {
+		return "change"
+	}
+	return fmt.Sprintf("M=%d", m)
+}
@@ -0,0 +1,51 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWalletData_Labels(t *testing.T) {
+	full, _ := testWatchOnlyPair(t)
+	d := NewWalletData(full)
+
+	exchange, err := d.CreateLabel("exchange-withdrawals")
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, exchange.M)
+	expected, err := GenerateLabel(*full, 1)
+	require.NoError(t, err)
+	assert.Equal(t, expected, exchange.Label)
+
+	// a label handed out by number only is skipped
+	handedOut, err := GenerateLabel(*full, 5)
+	require.NoError(t, err)
+	d.Labels = append(d.Labels, Label{Label: handedOut})
+
+	donations, err := d.CreateLabel("donations")
+	require.NoError(t, err)
+	assert.EqualValues(t, 6, donations.M)
+	require.NoError(t, d.Validate())
+
+	for _, name := range []string{"", "  ", "7", "donations"} {
+		_, err := d.CreateLabel(name)
+		assert.Error(t, err, "name %q", name)
+	}
+	_, err = d.CreateLabel("donations")
+	assert.ErrorIs(t, err, ErrLabelExists)
+
+	label, err := d.FindLabel("6")
+	require.NoError(t, err)
+	assert.Equal(t, "donations", label.Name)
+	_, err = d.FindLabel("missing")
+	assert.ErrorIs(t, err, ErrLabelNotFound)
+
+	require.NoError(t, d.RenameLabel("5", "shop"))
+	assert.ErrorIs(t, d.RenameLabel("shop", "donations"), ErrLabelExists)
+	assert.ErrorIs(t, d.RenameLabel("missing", "other"), ErrLabelNotFound)
+
+	assert.Equal(t, "shop", d.LabelName(5))
+	assert.Equal(t, "change", d.LabelName(0))
+	assert.Equal(t, "M=9", d.LabelName(9))
+}
@@ -0,0 +1,42 @@
+package wallet
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcquireLock(t *testing.T) {
+	dir := t.TempDir()
+
+	shared1, err := AcquireLock(dir, LockShared, time.Second)
+	require.NoError(t, err)
+	shared2, err := AcquireLock(dir, LockShared, time.Second)
+	require.NoError(t, err)
+
+	// readers block writers
+	_, err = AcquireLock(dir, LockExclusive, 200*time.Millisecond)
+	assert.ErrorIs(t, err, ErrLocked)
+	assert.Contains(t, err.Error(), fmt.Sprintf("PID %d", os.Getpid()))
+
+	require.NoError(t, shared1.Release())
+	require.NoError(t, shared2.Release())
+
+	exclusive, err := AcquireLock(dir, LockExclusive, time.Second)
+	require.NoError(t, err)
+
+	// writers block readers
+	_, err = AcquireLock(dir, LockShared, 200*time.Millisecond)
+	assert.ErrorIs(t, err, ErrLocked)
+
+	require.NoError(t, exclusive.Release())
+	require.NoError(t, exclusive.Release()) // double release is harmless
+
+	shared, err := AcquireLock(dir, LockShared, time.Second)
+	require.NoError(t, err)
+	require.NoError(t, shared.Release())
+}
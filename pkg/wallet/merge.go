@@ -0,0 +1,87 @@
+package wallet
+
+import (
+	scanwallet "github.com/setavenger/blindbit-scan/pkg/wallet"
+)
+
+// SyncDiff lists the coins that changed when merging a daemon scan into the wallet
+type SyncDiff struct {
+	// New coins the wallet did not know before
+	New []UTXO
+	// Spent coins the daemon now reports as spent
+	Spent []UTXO
+	// Confirmed coins that went from unconfirmed to unspent
+	Confirmed []UTXO
+	// Vanished coins the daemon no longer reports, e.g. after a reorg or a rescan with other keys
+	Vanished []UTXO
+}
+
+// Empty reports whether the merge changed nothing
+func (d SyncDiff) Empty() bool {
+	return len(d.New) == 0 && len(d.Spent) == 0 && len(d.Confirmed) == 0 && len(d.Vanished) == 0
+}
+
+type outpoint struct {
+	txid [32]byte
+	vout uint32
+}
+
+// MergeUTXOs merges the coins reported by the scan daemon into the wallet, keyed on outpoint.
+// Coin data and confirmations come from the daemon while local knowledge is kept:
+// a label the daemon did not report and a spend broadcast by this wallet the daemon has not seen yet.
+// Unspent coins the daemon no longer reports are removed, spent ones are kept as history.
+func (d *WalletData) MergeUTXOs(scanned []UTXO) SyncDiff {
+	var diff SyncDiff
+
+	remote := make(map[outpoint]UTXO, len(scanned))
+	for _, u := range scanned {
+		remote[outpoint{u.Txid, u.Vout}] = u
+	}
+
+	merged := make([]UTXO, 0, len(scanned))
+	seen := make(map[outpoint]bool, len(d.UTXOs))
+	for _, local := range d.UTXOs {
+		key := outpoint{local.Txid, local.Vout}
+		seen[key] = true
+
+		u, ok := remote[key]
+		if !ok {
+			if local.State == scanwallet.StateSpent {
+				merged = append(merged, local)
+			} else {
+				diff.Vanished = append(diff.Vanished, local)
+			}
+			continue
+		}
+
+		if u.Label == nil {
+			u.Label = local.Label
+		}
+
+		switch {
+		case local.State == scanwallet.StateUnconfirmedSpent &&
+			(u.State == scanwallet.StateUnspent || u.State == scanwallet.StateUnconfirmed):
+			// our spend is not mined yet
+			u.State = scanwallet.StateUnconfirmedSpent
+		case u.State == scanwallet.StateSpent && local.State != scanwallet.StateSpent:
+			diff.Spent = append(diff.Spent, u)
+		case u.State == scanwallet.StateUnspent && local.State == scanwallet.StateUnconfirmed:
+			diff.Confirmed = append(diff.Confirmed, u)
+		}
+
+		merged = append(merged, u)
+	}
+
+	for _, u := range scanned {
+		key := outpoint{u.Txid, u.Vout}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		merged = append(merged, u)
+		diff.New = append(diff.New, u)
+	}
+
+	d.UTXOs = merged
+	return diff
+}
@@ -0,0 +1,62 @@
+package wallet
+
+import (
+	"testing"
+
+	scanwallet "github.com/setavenger/blindbit-scan/pkg/wallet"
+	"github.com/setavenger/go-bip352"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWalletData_MergeUTXOs(t *testing.T) {
+	coin := func(id byte, amount uint64, state scanwallet.UTXOState) UTXO {
+		return UTXO{Txid: [32]byte{id}, Amount: amount, State: state}
+	}
+	label := &bip352.Label{M: 1}
+	labeled := coin(1, 1000, scanwallet.StateUnspent)
+	labeled.Label = label
+
+	d := &WalletData{UTXOs: []UTXO{
+		labeled,
+		coin(2, 2000, scanwallet.StateUnconfirmed),
+		coin(3, 3000, scanwallet.StateUnspent),
+		coin(4, 4000, scanwallet.StateUnconfirmedSpent),
+		coin(5, 5000, scanwallet.StateSpent),
+		coin(6, 6000, scanwallet.StateUnspent),
+		coin(7, 7000, scanwallet.StateUnconfirmedSpent),
+	}}
+
+	diff := d.MergeUTXOs([]UTXO{
+		coin(1, 1000, scanwallet.StateUnspent), // label not reported
+		coin(2, 2000, scanwallet.StateUnspent), // confirmed
+		coin(3, 3000, scanwallet.StateSpent),   // spent
+		coin(4, 4000, scanwallet.StateUnspent), // our spend is still pending
+		coin(7, 7000, scanwallet.StateSpent),   // our spend got mined
+		coin(8, 8000, scanwallet.StateUnconfirmed),
+		coin(8, 8000, scanwallet.StateUnconfirmed),
+		// 5 is pruned by the daemon, 6 vanished
+	})
+
+	amounts := func(utxos []UTXO) []uint64 {
+		var out []uint64
+		for _, u := range utxos {
+			out = append(out, u.Amount)
+		}
+		return out
+	}
+	assert.Equal(t, []uint64{8000}, amounts(diff.New))
+	assert.Equal(t, []uint64{3000, 7000}, amounts(diff.Spent))
+	assert.Equal(t, []uint64{2000}, amounts(diff.Confirmed))
+	assert.Equal(t, []uint64{6000}, amounts(diff.Vanished))
+	assert.False(t, diff.Empty())
+
+	assert.Equal(t, []uint64{1000, 2000, 3000, 4000, 5000, 7000, 8000}, amounts(d.UTXOs))
+	assert.Equal(t, label, d.UTXOs[0].Label)
+	assert.Equal(t, scanwallet.StateUnspent, d.UTXOs[1].State)
+	assert.Equal(t, scanwallet.StateUnconfirmedSpent, d.UTXOs[3].State)
+	assert.Equal(t, scanwallet.StateSpent, d.UTXOs[5].State)
+
+	// merging the same scan again changes nothing
+	scanned := append([]UTXO(nil), d.UTXOs...)
+	assert.True(t, d.MergeUTXOs(scanned).Empty())
+}
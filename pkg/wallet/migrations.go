@@ -0,0 +1,118 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// CurrentVersion is the WalletData schema version written by this build.
+// Bump it together with a new entry in migrations whenever the on-disk format changes.
+const CurrentVersion = 7
+
+// Migration upgrades raw wallet JSON from version From to From+1.
+// Migrations work on the raw JSON objects so they keep working after the Go types changed.
+type Migration struct {
+	From        int
+	Description string
+	Migrate     func(doc map[string]json.RawMessage) error
+}
+
+// migrations is the ordered registry, one step per version
+var migrations = []Migration{
+	{
+		From:        0,
+		Description: "add schema version, replace null utxos/labels with empty lists",
+		Migrate: func(doc map[string]json.RawMessage) error {
+			for _, key := range []string{"utxos", "labels"} {
+				if v, ok := doc[key]; !ok || bytes.Equal(bytes.TrimSpace(v), []byte("null")) {
+					doc[key] = json.RawMessage("[]")
+				}
+			}
+			return nil
+		},
+	},
+	// The following steps add optional fields that older builds would silently drop on their next save.
+	// Nothing has to be rewritten, the version bump makes older builds refuse the file instead.
+	{From: 1, Description: "watch-only wallets: wallet.spend_public", Migrate: noMigration},
+	{From: 2, Description: "wallet birth height: birth_height", Migrate: noMigration},
+	{From: 3, Description: "pending transactions: pending_txs", Migrate: noMigration},
+	{From: 4, Description: "transaction log: history", Migrate: noMigration},
+	{From: 5, Description: "label names: labels[].name", Migrate: noMigration},
+	{From: 6, Description: "frozen coins: frozen", Migrate: noMigration},
+}
+
+// noMigration is the step for schema changes that only add fields with a usable zero value
+func noMigration(map[string]json.RawMessage) error { return nil }
+
+// DataVersion returns the schema version of raw wallet JSON. Files without a version field are version 0.
+func DataVersion(data []byte) (int, error) {
+	var probe struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return 0, fmt.Errorf("failed to read wallet data version: %w", err)
+	}
+	return probe.Version, nil
+}
+
+// UpgradeData applies all migrations needed to bring raw wallet JSON to CurrentVersion.
+// It returns the upgraded JSON and the migrations that were applied (none if already current).
+func UpgradeData(data []byte) ([]byte, []Migration, error) {
+	version, err := DataVersion(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if version > CurrentVersion {
+		return nil, nil, fmt.Errorf("wallet data version %d is newer than supported version %d, please upgrade blindbit-wallet-cli", version, CurrentVersion)
+	}
+	if version == CurrentVersion {
+		return data, nil, nil
+	}
+
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, nil, fmt.Errorf("failed to unmarshal wallet data: %w", err)
+	}
+
+	var applied []Migration
+	for _, m := range migrations {
+		if m.From < version {
+			continue
+		}
+		if m.From != version {
+			return nil, nil, fmt.Errorf("no migration registered from version %d", version)
+		}
+		if err := m.Migrate(doc); err != nil {
+			return nil, nil, fmt.Errorf("migration from version %d failed: %w", m.From, err)
+		}
+		version = m.From + 1
+		doc["version"] = json.RawMessage(fmt.Sprintf("%d", version))
+		applied = append(applied, m)
+	}
+	if version != CurrentVersion {
+		return nil, nil, fmt.Errorf("no migration registered from version %d", version)
+	}
+
+	upgraded, err := json.Marshal(doc)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal upgraded wallet data: %w", err)
+	}
+
+	return upgraded, applied, nil
+}
+
+// DecodeData upgrades raw wallet JSON to the current schema and unmarshals it
+func DecodeData(data []byte) (*WalletData, []Migration, error) {
+	upgraded, applied, err := UpgradeData(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var walletData WalletData
+	if err := json.Unmarshal(upgraded, &walletData); err != nil {
+		return nil, nil, fmt.Errorf("failed to unmarshal wallet data: %w", err)
+	}
+
+	return &walletData, applied, nil
+}
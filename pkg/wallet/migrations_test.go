@@ -0,0 +1,54 @@
+package wallet
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpgradeData(t *testing.T) {
+	// a file as written before the version field existed
+	v0 := []byte(`{"wallet":{"network":"signet","mnemonic":"","scan_secret":"","spend_secret":""},"utxos":null,"last_height":42,"labels":null}`)
+
+	upgraded, applied, err := UpgradeData(v0)
+	require.NoError(t, err)
+	assert.Len(t, applied, CurrentVersion)
+
+	version, err := DataVersion(upgraded)
+	require.NoError(t, err)
+	assert.Equal(t, CurrentVersion, version)
+
+	var doc map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(upgraded, &doc))
+	assert.JSONEq(t, `[]`, string(doc["utxos"]))
+	assert.JSONEq(t, `[]`, string(doc["labels"]))
+
+	data, applied, err := DecodeData(upgraded)
+	require.NoError(t, err)
+	assert.Empty(t, applied)
+	assert.Equal(t, int64(42), data.LastHeight)
+	assert.Equal(t, NetworkSignet, data.Wallet.Network)
+}
+
+func TestUpgradeDataRejectsNewerVersion(t *testing.T) {
+	_, _, err := UpgradeData([]byte(fmt.Sprintf(`{"version":%d}`, CurrentVersion+1)))
+	assert.Error(t, err)
+}
+
+func TestMigrationsAreContiguous(t *testing.T) {
+	require.Len(t, migrations, CurrentVersion)
+	for i, m := range migrations {
+		assert.Equal(t, i, m.From)
+		assert.NotEmpty(t, m.Description)
+	}
+
+	// every older version upgrades with exactly the missing steps
+	for version := 1; version < CurrentVersion; version++ {
+		_, applied, err := UpgradeData([]byte(fmt.Sprintf(`{"version":%d,"utxos":[],"labels":[]}`, version)))
+		require.NoError(t, err)
+		assert.Len(t, applied, CurrentVersion-version)
+	}
+}
@@ -0,0 +1,133 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+	scanwallet "github.com/setavenger/blindbit-scan/pkg/wallet"
+)
+
+// PendingTx is a transaction built by this wallet whose inputs the scan daemon has not reported as spent yet
+type PendingTx struct {
+	Txid  string `json:"txid"`
+	RawTx string `json:"raw_tx"`
+	// Inputs are the spent outpoints as txid:vout
+	Inputs []string `json:"inputs"`
+	// Change is the outpoint of the change output, empty if the transaction has no change
+	Change       string    `json:"change,omitempty"`
+	ChangeAmount uint64    `json:"change_amount,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// FormatOutpoint formats an outpoint as txid:vout
+func FormatOutpoint(txid [32]byte, vout uint32) string {
+	return fmt.Sprintf("%x:%d", txid, vout)
+}
+
+// addPendingTx marks the spent coins as unconfirmed_spent and records the transaction as pending and in the history
+func (d *WalletData) addPendingTx(tx *wire.MsgTx, spent []*UTXO, changeScript []byte) {
+	var raw bytes.Buffer
+	_ = tx.Serialize(&raw) // serializing into a buffer can't fail
+
+	pending := PendingTx{
+		Txid:      tx.TxHash().String(),
+		RawTx:     hex.EncodeToString(raw.Bytes()),
+		CreatedAt: time.Now(),
+	}
+
+	for _, utxo := range spent {
+		utxo.State = scanwallet.StateUnconfirmedSpent
+		pending.Inputs = append(pending.Inputs, FormatOutpoint(utxo.Txid, utxo.Vout))
+	}
+
+	if len(changeScript) > 0 {
+		for i, out := range tx.TxOut {
+			if bytes.Equal(out.PkScript, changeScript) {
+				pending.Change = fmt.Sprintf("%s:%d", pending.Txid, i)
+				pending.ChangeAmount = uint64(out.Value)
+				break
+			}
+		}
+	}
+
+	var sumOutputs uint64
+	for _, out := range tx.TxOut {
+		sumOutputs += uint64(out.Value)
+	}
+
+	d.PendingTxs = append(d.PendingTxs, pending)
+	d.recordSend(pending, spent, sumOutputs)
+}
+
+// PendingTx returns the pending transaction spending the outpoint, nil if there is none
+func (d *WalletData) PendingTx(outpoint string) *PendingTx {
+	for i := range d.PendingTxs {
+		for _, input := range d.PendingTxs[i].Inputs {
+			if input == outpoint {
+				return &d.PendingTxs[i]
+			}
+		}
+	}
+	return nil
+}
+
+// ReconcilePending drops the pending transactions that are resolved after a sync and returns them.
+// A transaction is resolved once none of its inputs is still waiting as unconfirmed_spent,
+// i.e. the daemon reported them as spent or no longer reports them at all.
+func (d *WalletData) ReconcilePending() []PendingTx {
+	waiting := make(map[string]bool)
+	for _, u := range d.UTXOs {
+		if u.State == scanwallet.StateUnconfirmedSpent {
+			waiting[FormatOutpoint(u.Txid, u.Vout)] = true
+		}
+	}
+
+	var resolved []PendingTx
+	kept := d.PendingTxs[:0]
+	for _, pending := range d.PendingTxs {
+		var isWaiting bool
+		for _, input := range pending.Inputs {
+			if waiting[input] {
+				isWaiting = true
+				break
+			}
+		}
+		if isWaiting {
+			kept = append(kept, pending)
+		} else {
+			resolved = append(resolved, pending)
+		}
+	}
+	d.PendingTxs = kept
+
+	return resolved
+}
+
+// AbandonPending forgets a pending transaction that was never broadcast and makes its inputs spendable again
+func (d *WalletData) AbandonPending(txid string) error {
+	for i, pending := range d.PendingTxs {
+		if pending.Txid != txid {
+			continue
+		}
+
+		inputs := make(map[string]bool, len(pending.Inputs))
+		for _, input := range pending.Inputs {
+			inputs[input] = true
+		}
+		for j := range d.UTXOs {
+			u := &d.UTXOs[j]
+			if u.State == scanwallet.StateUnconfirmedSpent && inputs[FormatOutpoint(u.Txid, u.Vout)] {
+				u.State = scanwallet.StateUnspent
+			}
+		}
+
+		d.PendingTxs = append(d.PendingTxs[:i], d.PendingTxs[i+1:]...)
+		d.removeTxRecord(txid)
+		return nil
+	}
+
+	return fmt.Errorf("no pending transaction %s", txid)
+}
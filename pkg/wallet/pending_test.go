@@ -0,0 +1,98 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+	scanwallet "github.com/setavenger/blindbit-scan/pkg/wallet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSendToRecipients_TracksPendingSpends(t *testing.T) {
+	full, _ := testWatchOnlyPair(t)
+	d := NewWalletData(full)
+	for _, u := range testOwnedUTXOs(t, full, 50_000, 50_000) {
+		d.UTXOs = append(d.UTXOs, *u)
+	}
+
+	destination, err := btcutil.NewAddressTaproot(d.UTXOs[0].PubKey[:], &chaincfg.SigNetParams)
+	require.NoError(t, err)
+	send := func() ([]byte, error) {
+		txBytes, _, err := SendToRecipients(d, []Recipient{&RecipientImpl{Address: destination.String(), Amount: 20_000}}, SatPerVByte(2), CoinControl{})
+		return txBytes, err
+	}
+
+	txBytes, err := send()
+	require.NoError(t, err)
+	var tx wire.MsgTx
+	require.NoError(t, tx.Deserialize(bytes.NewReader(txBytes)))
+
+	require.Len(t, d.PendingTxs, 1)
+	pending := d.PendingTxs[0]
+	assert.Equal(t, tx.TxHash().String(), pending.Txid)
+	assert.Equal(t, hex.EncodeToString(txBytes), pending.RawTx)
+	require.Len(t, pending.Inputs, 1)
+	assert.Equal(t, FormatOutpoint(d.UTXOs[0].Txid, d.UTXOs[0].Vout), pending.Inputs[0])
+	assert.Equal(t, scanwallet.StateUnconfirmedSpent, d.UTXOs[0].State)
+	assert.NotEmpty(t, pending.Change)
+	assert.NotZero(t, pending.ChangeAmount)
+	assert.Equal(t, &d.PendingTxs[0], d.PendingTx(pending.Inputs[0]))
+
+	// the second send must not pick the same coin again
+	_, err = send()
+	require.NoError(t, err)
+	require.Len(t, d.PendingTxs, 2)
+	assert.NotEqual(t, d.PendingTxs[0].Inputs, d.PendingTxs[1].Inputs)
+
+	_, err = send()
+	assert.ErrorIs(t, err, ErrInsufficientFunds)
+
+	// the daemon saw the first spend, the second one was never broadcast
+	d.UTXOs[0].State = scanwallet.StateSpent
+	resolved := d.ReconcilePending()
+	require.Len(t, resolved, 1)
+	assert.Equal(t, pending.Txid, resolved[0].Txid)
+	require.Len(t, d.PendingTxs, 1)
+
+	require.NoError(t, d.AbandonPending(d.PendingTxs[0].Txid))
+	assert.Empty(t, d.PendingTxs)
+	assert.Equal(t, scanwallet.StateUnspent, d.UTXOs[1].State)
+	assert.Error(t, d.AbandonPending(pending.Txid))
+}
+
+func TestSelectCoins_DoesNotModifyWallet(t *testing.T) {
+	full, _ := testWatchOnlyPair(t)
+	d := NewWalletData(full)
+	for _, u := range testOwnedUTXOs(t, full, 50_000, 50_000) {
+		d.UTXOs = append(d.UTXOs, *u)
+	}
+
+	destination, err := btcutil.NewAddressTaproot(d.UTXOs[0].PubKey[:], &chaincfg.SigNetParams)
+	require.NoError(t, err)
+	recipients := []Recipient{&RecipientImpl{Address: destination.String(), Amount: 20_000}}
+
+	selection, err := SelectCoins(d, recipients, SatPerVByte(2), CoinControl{})
+	require.NoError(t, err)
+	require.Len(t, selection.Inputs, 1)
+
+	sweep, err := SelectSweep(d, destination.String(), SatPerVByte(2), CoinControl{})
+	require.NoError(t, err)
+	assert.Equal(t, StrategySweep, sweep.Strategy)
+	assert.Len(t, sweep.Inputs, 2)
+	assert.Zero(t, sweep.Change)
+
+	assert.Empty(t, d.PendingTxs)
+	assert.Empty(t, d.History)
+	for _, u := range d.UTXOs {
+		assert.Equal(t, scanwallet.StateUnspent, u.State)
+	}
+
+	_, sent, err := SendToRecipients(d, recipients, SatPerVByte(2), CoinControl{})
+	require.NoError(t, err)
+	assert.Equal(t, selection, sent)
+}
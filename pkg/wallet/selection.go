@@ -0,0 +1,221 @@
+package wallet
+
+import (
+	"cmp"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+// CoinSelectionStrategy names a coin selection algorithm
+type CoinSelectionStrategy string
+
+const (
+	// StrategyBnB looks for a changeless solution and falls back to accumulating coins in wallet order
+	StrategyBnB CoinSelectionStrategy = "bnb"
+	// StrategyLargestFirst spends the largest coins first, fewest inputs
+	StrategyLargestFirst CoinSelectionStrategy = "largest-first"
+	// StrategySmallestFirst spends the smallest coins first, consolidating them while fees are low
+	StrategySmallestFirst CoinSelectionStrategy = "smallest-first"
+	// StrategyRandom draws coins in random order
+	StrategyRandom CoinSelectionStrategy = "random"
+	// StrategyOldestFirst spends the coins received first
+	StrategyOldestFirst CoinSelectionStrategy = "oldest-first"
+
+	DefaultCoinSelectionStrategy = StrategyBnB
+
+	// StrategySweep is not selectable, it marks the selection of a sweep which spends every coin
+	StrategySweep CoinSelectionStrategy = "sweep"
+)
+
+// CoinSelectionStrategies lists all strategies, the default first
+var CoinSelectionStrategies = []CoinSelectionStrategy{
+	StrategyBnB, StrategyLargestFirst, StrategySmallestFirst, StrategyRandom, StrategyOldestFirst,
+}
+
+// LongTermFeeRate is the fee rate at which coins are expected to be spent later.
+// Spending inputs above it adds to the waste, below it saves.
+var LongTermFeeRate = SatPerVByte(10)
+
+// ParseCoinSelectionStrategy validates a strategy name, empty means the default
+func ParseCoinSelectionStrategy(s string) (CoinSelectionStrategy, error) {
+	if s == "" {
+		return DefaultCoinSelectionStrategy, nil
+	}
+	strategy := CoinSelectionStrategy(s)
+	if !slices.Contains(CoinSelectionStrategies, strategy) {
+		return "", fmt.Errorf("unknown coin selection strategy %q, use one of %v", s, CoinSelectionStrategies)
+	}
+	return strategy, nil
+}
+
+// Selection is the outcome of a coin selection
+type Selection struct {
+	Strategy CoinSelectionStrategy
+	Inputs   []*UTXO
+	// Change is 0 if the transaction has no change output
+	Change uint64
+	// VSize is the estimated size of the signed transaction including the change output
+	VSize int64
+	// Fee is everything the inputs pay beyond the recipients and the change
+	Fee uint64
+	// Waste compares selections, lower is better: the input fees above LongTermFeeRate
+	// plus the cost of the change output, or the excess dropped to fees without change
+	Waste int64
+}
+
+func (s Selection) String() string {
+	return fmt.Sprintf("%s, %d inputs, change %d sats, %d vB, fee %d sats, waste %d",
+		s.Strategy, len(s.Inputs), s.Change, s.VSize, s.Fee, s.Waste)
+}
+
+// CoinSelector picks the coins for a transaction
+type CoinSelector interface {
+	Select(feeRate FeeRate) (Selection, error)
+}
+
+// NewCoinSelector returns the selector for the strategy.
+// With spendAll every coin is spent, the strategy then only decides on the change.
+// With subtractFee the coins only have to cover the recipient amounts, the recipients pay the fee.
+func NewCoinSelector(
+	strategy CoinSelectionStrategy,
+	utxos []*UTXO,
+	minChangeAmount uint64,
+	recipients []Recipient,
+	chainParams *chaincfg.Params,
+	spendAll, subtractFee bool,
+) (
+	CoinSelector, error,
+) {
+	switch strategy {
+	case "", StrategyBnB:
+		selector := NewBnBCoinSelector(utxos, minChangeAmount, recipients, chainParams)
+		selector.SpendAll = spendAll
+		selector.SubtractFee = subtractFee
+		return selector, nil
+	case StrategyLargestFirst, StrategySmallestFirst, StrategyRandom, StrategyOldestFirst:
+		selector := &SortedCoinSelector{
+			FeeRateCoinSelector: *NewFeeRateCoinSelector(utxos, minChangeAmount, recipients, chainParams),
+			Strategy:            strategy,
+		}
+		selector.SpendAll = spendAll
+		selector.SubtractFee = subtractFee
+		return selector, nil
+	default:
+		return nil, fmt.Errorf("unknown coin selection strategy %q", strategy)
+	}
+}
+
+// SortedCoinSelector orders the coins by its strategy and accumulates them like the FeeRateCoinSelector
+type SortedCoinSelector struct {
+	FeeRateCoinSelector
+	Strategy CoinSelectionStrategy
+}
+
+// CoinSelect
+// returns the utxos to select and the change amount, see FeeRateCoinSelector.CoinSelect
+func (s *SortedCoinSelector) CoinSelect(
+	feeRate FeeRate,
+) (
+	[]*UTXO, uint64, error,
+) {
+	selector := s.FeeRateCoinSelector
+	selector.OwnedUTXOs = slices.Clone(s.OwnedUTXOs)
+
+	switch s.Strategy {
+	case StrategyLargestFirst:
+		slices.SortStableFunc(selector.OwnedUTXOs, func(a, b *UTXO) int { return cmp.Compare(b.Amount, a.Amount) })
+	case StrategySmallestFirst:
+		slices.SortStableFunc(selector.OwnedUTXOs, func(a, b *UTXO) int { return cmp.Compare(a.Amount, b.Amount) })
+	case StrategyOldestFirst:
+		slices.SortStableFunc(selector.OwnedUTXOs, func(a, b *UTXO) int { return cmp.Compare(a.Timestamp, b.Timestamp) })
+	case StrategyRandom:
+		rand.Shuffle(len(selector.OwnedUTXOs), func(i, j int) {
+			selector.OwnedUTXOs[i], selector.OwnedUTXOs[j] = selector.OwnedUTXOs[j], selector.OwnedUTXOs[i]
+		})
+	default:
+		return nil, 0, fmt.Errorf("unknown coin selection strategy %q", s.Strategy)
+	}
+
+	return selector.CoinSelect(feeRate)
+}
+
+func (s *SortedCoinSelector) Select(feeRate FeeRate) (Selection, error) {
+	inputs, change, err := s.CoinSelect(feeRate)
+	if err != nil {
+		return Selection{}, err
+	}
+	return newSelection(s.Strategy, inputs, change, s.Recipients, s.ChainParams, feeRate, s.SubtractFee)
+}
+
+func (s *BnBCoinSelector) Select(feeRate FeeRate) (Selection, error) {
+	inputs, change, err := s.CoinSelect(feeRate)
+	if err != nil {
+		return Selection{}, err
+	}
+	return newSelection(StrategyBnB, inputs, change, s.Recipients, s.ChainParams, feeRate, s.SubtractFee)
+}
+
+// changeOutputFee is the fee to add a taproot change output at the fee rate
+func changeOutputFee(feeRate FeeRate) uint64 {
+	return feeRate.Fee(VSize(OutputWeight(ScriptPubKeyTaprootLen)))
+}
+
+// maxDroppedExcess is the most a changeless selection drops to the fee: the cost of change for BnB,
+// or a leftover that could not pay for a change output of minChangeAmount
+func maxDroppedExcess(feeRate FeeRate, minChangeAmount uint64) uint64 {
+	return max(changeOutputFee(feeRate)+InputFee(feeRate), minChangeAmount+changeOutputFee(feeRate))
+}
+
+// newSelection computes the size, fee and waste of the selected inputs.
+// With subtractFee the recipients pay the part of the fee the inputs don't cover.
+func newSelection(
+	strategy CoinSelectionStrategy,
+	inputs []*UTXO,
+	change uint64,
+	recipients []Recipient,
+	chainParams *chaincfg.Params,
+	feeRate FeeRate,
+	subtractFee bool,
+) (
+	Selection, error,
+) {
+	outputLens, err := extractPkScriptsFromRecipients(recipients, chainParams)
+	if err != nil {
+		return Selection{}, err
+	}
+	if change > 0 {
+		outputLens = append(outputLens, ScriptPubKeyTaprootLen)
+	}
+
+	var sumInputs, sumRecipients uint64
+	for _, utxo := range inputs {
+		sumInputs += utxo.Amount
+	}
+	for _, recipient := range recipients {
+		sumRecipients += recipient.GetAmount()
+	}
+
+	selection := Selection{
+		Strategy: strategy,
+		Inputs:   inputs,
+		Change:   change,
+		VSize:    EstimateVSize(len(inputs), outputLens),
+		Fee:      sumInputs - sumRecipients - change,
+	}
+	if subtractFee {
+		// a changeless selection drops its excess to the fee, it pays at least the needed fee
+		selection.Fee = max(selection.Fee, NeededFeeAbsolutSats(selection.VSize, feeRate))
+	}
+
+	selection.Waste = int64(len(inputs)) * (int64(InputFee(feeRate)) - int64(InputFee(LongTermFeeRate)))
+	if change > 0 {
+		selection.Waste += int64(changeOutputFee(feeRate) + InputFee(LongTermFeeRate))
+	} else {
+		selection.Waste += int64(selection.Fee) - int64(NeededFeeAbsolutSats(selection.VSize, feeRate))
+	}
+
+	return selection, nil
+}
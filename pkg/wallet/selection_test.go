@@ -0,0 +1,160 @@
+package wallet
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCoinSelector_Select(t *testing.T) {
+	// amounts and timestamps differ in order so every strategy picks differently
+	utxos := func() []*UTXO {
+		return []*UTXO{
+			{Amount: 30_000, Timestamp: 300},
+			{Amount: 10_000, Timestamp: 100},
+			{Amount: 50_000, Timestamp: 200},
+			{Amount: 20_000, Timestamp: 400},
+		}
+	}
+	recipients := []Recipient{
+		&RecipientImpl{
+			Address: "bc1qua7e852suw0p74e2lzxwmk2tw8fd2zuzexc866",
+			Amount:  25_000,
+		},
+	}
+
+	testCases := []struct {
+		strategy CoinSelectionStrategy
+		amounts  []uint64
+		change   uint64
+	}{
+		{strategy: StrategyBnB, amounts: []uint64{30_000}, change: 4858},
+		{strategy: StrategyLargestFirst, amounts: []uint64{50_000}, change: 24858},
+		{strategy: StrategySmallestFirst, amounts: []uint64{10_000, 20_000}, change: 4800},
+		{strategy: StrategyOldestFirst, amounts: []uint64{10_000, 50_000}, change: 34800},
+	}
+
+	for _, tc := range testCases {
+		t.Run(string(tc.strategy), func(t *testing.T) {
+			selector, err := NewCoinSelector(tc.strategy, utxos(), DustLimit, recipients, &chaincfg.MainNetParams, false, false)
+			require.NoError(t, err)
+
+			selection, err := selector.Select(SatPerVByte(1))
+			require.NoError(t, err)
+
+			var amounts []uint64
+			var sumInputs uint64
+			for _, utxo := range selection.Inputs {
+				amounts = append(amounts, utxo.Amount)
+				sumInputs += utxo.Amount
+			}
+			assert.Equal(t, tc.strategy, selection.Strategy)
+			assert.Equal(t, tc.amounts, amounts)
+			assert.Equal(t, tc.change, selection.Change)
+			assert.Equal(t, sumInputs-25_000-tc.change, selection.Fee)
+			assert.Equal(t, NeededFeeAbsolutSats(selection.VSize, SatPerVByte(1)), selection.Fee)
+		})
+	}
+}
+
+func TestCoinSelector_Random(t *testing.T) {
+	var utxos []*UTXO
+	for i := range 20 {
+		utxos = append(utxos, &UTXO{Amount: uint64(i+1) * 10_000})
+	}
+	recipients := []Recipient{
+		&RecipientImpl{
+			Address: "bc1qua7e852suw0p74e2lzxwmk2tw8fd2zuzexc866",
+			Amount:  100_000,
+		},
+	}
+
+	selector, err := NewCoinSelector(StrategyRandom, utxos, DustLimit, recipients, &chaincfg.MainNetParams, false, false)
+	require.NoError(t, err)
+
+	for range 10 {
+		selection, err := selector.Select(SatPerVByte(2))
+		require.NoError(t, err)
+
+		var sumInputs uint64
+		for _, utxo := range selection.Inputs {
+			sumInputs += utxo.Amount
+		}
+		assert.Equal(t, sumInputs, 100_000+selection.Change+selection.Fee)
+		assert.GreaterOrEqual(t, selection.Change, uint64(DustLimit))
+		assert.Equal(t, NeededFeeAbsolutSats(selection.VSize, SatPerVByte(2)), selection.Fee)
+	}
+
+	// the coins are shuffled in a copy, the caller's order is kept
+	for i, utxo := range utxos {
+		assert.Equal(t, uint64(i+1)*10_000, utxo.Amount)
+	}
+}
+
+func TestSelection_Waste(t *testing.T) {
+	recipients := []Recipient{
+		&RecipientImpl{
+			Address: "bc1qua7e852suw0p74e2lzxwmk2tw8fd2zuzexc866",
+			Amount:  39_850,
+		},
+	}
+	utxos := []*UTXO{{Amount: 40_000}}
+
+	// without change the excess above the needed fee is the waste, the input is cheaper than at the long term rate
+	selection, err := newSelection(StrategyBnB, utxos, 0, recipients, &chaincfg.MainNetParams, SatPerVByte(1), false)
+	require.NoError(t, err)
+	excess := int64(selection.Fee) - int64(NeededFeeAbsolutSats(selection.VSize, SatPerVByte(1)))
+	assert.Equal(t, int64(InputFee(SatPerVByte(1)))-int64(InputFee(LongTermFeeRate))+excess, selection.Waste)
+
+	// with change the cost of creating and spending the change output counts instead
+	selection, err = newSelection(StrategyLargestFirst, utxos, 50, recipients, &chaincfg.MainNetParams, SatPerVByte(1), false)
+	require.NoError(t, err)
+	costOfChange := int64(changeOutputFee(SatPerVByte(1)) + InputFee(LongTermFeeRate))
+	assert.Equal(t, int64(InputFee(SatPerVByte(1)))-int64(InputFee(LongTermFeeRate))+costOfChange, selection.Waste)
+	assert.Equal(t, uint64(100), selection.Fee)
+}
+
+func TestParseCoinSelectionStrategy(t *testing.T) {
+	strategy, err := ParseCoinSelectionStrategy("")
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultCoinSelectionStrategy, strategy)
+
+	strategy, err = ParseCoinSelectionStrategy("smallest-first")
+	assert.NoError(t, err)
+	assert.Equal(t, StrategySmallestFirst, strategy)
+
+	_, err = ParseCoinSelectionStrategy("knapsack")
+	assert.Error(t, err)
+}
+
+func TestSendToRecipients_SubDustLeftover(t *testing.T) {
+	full, _ := testWatchOnlyPair(t)
+	destination, err := btcutil.NewAddressTaproot(make([]byte, 32), &chaincfg.SigNetParams)
+	require.NoError(t, err)
+
+	// the leftover is above the cost of change but below the dust limit, it goes to the fee
+	for _, strategy := range CoinSelectionStrategies {
+		for _, amount := range []uint64{49_400, 49_500, 49_600, 49_700} {
+			d := NewWalletData(full)
+			for _, u := range testOwnedUTXOs(t, full, 50_000) {
+				d.UTXOs = append(d.UTXOs, *u)
+			}
+
+			recipients := []Recipient{&RecipientImpl{Address: destination.String(), Amount: amount}}
+			txBytes, selection, err := SendToRecipients(d, recipients, SatPerVByte(1), CoinControl{Strategy: strategy})
+			require.NoError(t, err, "%s %d", strategy, amount)
+
+			var tx wire.MsgTx
+			require.NoError(t, tx.Deserialize(bytes.NewReader(txBytes)))
+			require.Len(t, tx.TxOut, 1)
+			assert.EqualValues(t, amount, tx.TxOut[0].Value)
+			assert.Zero(t, selection.Change)
+			assert.Equal(t, 50_000-amount, selection.Fee)
+		}
+	}
+}
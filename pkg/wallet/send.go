@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"log"
+	"slices"
 
 	"github.com/btcsuite/btcd/btcec/v2"
 	"github.com/btcsuite/btcd/btcec/v2/schnorr"
@@ -12,203 +13,536 @@ import (
 	"github.com/btcsuite/btcd/btcutil/txsort"
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/mempool"
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
 	scanwallet "github.com/setavenger/blindbit-scan/pkg/wallet"
 	"github.com/setavenger/go-bip352"
 )
 
-// SendToRecipients sends Bitcoin to the given recipients
+// SendToRecipients sends Bitcoin to the given recipients, spending coins allowed by the coin control.
+// The spent coins are marked as unconfirmed_spent and the transaction is recorded as pending
+// so the next send does not select them again before a sync. The coin selection is returned for display.
 func SendToRecipients(
 	walletData *WalletData,
 	recipients []Recipient,
-	feeRate uint32,
+	feeRate FeeRate,
+	coinControl CoinControl,
 ) (
 	[]byte,
+	Selection,
 	error,
 ) {
-	// Convert recipients to coin selector format
-	var selectorRecipients []Recipient
-	for _, r := range recipients {
-		selectorRecipients = append(selectorRecipients, r)
+	chainParams, err := walletData.Wallet.Network.ChainParams()
+	if err != nil {
+		return nil, Selection{}, err
 	}
 
-	// Convert UTXOs to coin selector format
-	var utxos scanwallet.UtxoCollection
-	for _, u := range walletData.UTXOs {
-		if u.State != scanwallet.StateUnspent {
-			continue
-		}
-		utxos = append(utxos, &u)
-	}
-
-	// Get chain parameters
-	var chainParams *chaincfg.Params
-	switch walletData.Wallet.Network {
-	case NetworkMainnet:
-		chainParams = &chaincfg.MainNetParams
-	case NetworkTestnet:
-		chainParams = &chaincfg.TestNet3Params
-	case NetworkSignet:
-		chainParams = &chaincfg.SigNetParams
-	default:
-		return nil, fmt.Errorf("unsupported network: %s", walletData.Wallet.Network)
+	utxos, err := walletData.selectableUTXOs(coinControl)
+	if err != nil {
+		return nil, Selection{}, err
 	}
 
-	// Send to recipients
-	return walletData.Wallet.SendToRecipients(
-		selectorRecipients,
+	finalTx, selection, changeScript, err := walletData.Wallet.buildTx(
+		recipients,
 		utxos,
-		int64(feeRate),
+		feeRate,
 		chainParams,
-		546,   // Minimum change amount
-		false, // Don't mark as spent
-		false, // Don't use unconfirmed spent
+		DustLimit, // Minimum change amount
+		coinControl,
 	)
+	if err != nil {
+		return nil, Selection{}, err
+	}
+
+	var buf bytes.Buffer
+	err = finalTx.Serialize(&buf)
+	if err != nil {
+		return nil, Selection{}, err
+	}
+
+	walletData.addPendingTx(finalTx, selection.Inputs, changeScript)
+
+	return buf.Bytes(), selection, nil
 }
 
+// SelectCoins runs the coin selection of SendToRecipients without building or signing anything
+func SelectCoins(
+	walletData *WalletData,
+	recipients []Recipient,
+	feeRate FeeRate,
+	coinControl CoinControl,
+) (
+	Selection,
+	error,
+) {
+	chainParams, err := walletData.Wallet.Network.ChainParams()
+	if err != nil {
+		return Selection{}, err
+	}
+
+	utxos, err := walletData.selectableUTXOs(coinControl)
+	if err != nil {
+		return Selection{}, err
+	}
+
+	selection, _, err := walletData.Wallet.selectCoins(recipients, utxos, feeRate, chainParams, DustLimit, coinControl)
+	return selection, err
+}
+
+// unspentUTXOs returns the confirmed unspent coins, the only ones used for coin selection.
+// The pointers point into d.UTXOs.
+func (d *WalletData) unspentUTXOs() scanwallet.UtxoCollection {
+	var utxos scanwallet.UtxoCollection
+	for i := range d.UTXOs {
+		if d.UTXOs[i].State != scanwallet.StateUnspent {
+			continue
+		}
+		utxos = append(utxos, &d.UTXOs[i])
+	}
+	return utxos
+}
+
+// SendToRecipients builds and signs a transaction from the given utxos.
+// With markSpent the spent utxos are set to unconfirmed_spent in the caller's collection.
 func (w Wallet) SendToRecipients(
 	recipients []Recipient,
 	utxos scanwallet.UtxoCollection,
-	feeRate int64,
+	feeRate FeeRate,
 	chainParams *chaincfg.Params,
 	minChangeAmount uint64,
-	markSpent, useSpentUnconfirmed bool,
+	markSpent bool,
 ) (
 	txBytes []byte,
 	err error,
 ) {
-	// var chainParams *chaincfg.Params
-	// switch w.Network {
-	// case NetworkMainnet:
-	// 	chainParams = &chaincfg.MainNetParams
-	// case NetworkTestnet:
-	// 	chainParams = &chaincfg.TestNet3Params
-	// case NetworkSignet:
-	// 	chainParams = &chaincfg.SigNetParams
-	// case NetworkRegtest:
-	// 	chainParams = &chaincfg.RegressionNetParams
-	// default:
-	// 	return nil, fmt.Errorf("network not covered: %s", w.Network)
-	// }
-	//
-	selector := NewFeeRateCoinSelector(utxos, minChangeAmount, recipients, chainParams)
-
-	selectedUTXOs, changeAmount, err := selector.CoinSelect(uint32(feeRate))
+	finalTx, selection, _, err := w.buildTx(recipients, utxos, feeRate, chainParams, minChangeAmount, CoinControl{})
 	if err != nil {
 		return nil, err
 	}
 
-	fmt.Println("change:", changeAmount)
+	var buf bytes.Buffer
+	err = finalTx.Serialize(&buf)
+	if err != nil {
+		return nil, err
+	}
+
+	if markSpent {
+		// the utxos point into the caller's collection, a second send won't select them again
+		for _, utxo := range selection.Inputs {
+			utxo.State = scanwallet.StateUnconfirmedSpent
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// buildTx selects the coins, adds change and builds the signed transaction.
+// The coin control picks the strategy, with Only set every given coin is spent instead of only as many as needed.
+// It also returns the coin selection and the output script of the change output (nil without change).
+func (w Wallet) buildTx(
+	recipients []Recipient,
+	utxos scanwallet.UtxoCollection,
+	feeRate FeeRate,
+	chainParams *chaincfg.Params,
+	minChangeAmount uint64,
+	coinControl CoinControl,
+) (
+	*wire.MsgTx,
+	Selection,
+	[]byte,
+	error,
+) {
+	if w.IsWatchOnly() {
+		return nil, Selection{}, nil, ErrWatchOnly
+	}
+
+	selection, recipients, err := w.selectCoins(recipients, utxos, feeRate, chainParams, minChangeAmount, coinControl)
+	if err != nil {
+		return nil, Selection{}, nil, err
+	}
 
 	// vins is the final selection of coins, which can then be used to derive silentPayment Outputs
-	var vins = make([]*bip352.Vin, len(selectedUTXOs))
-	for i, utxo := range selectedUTXOs {
-		vin := ConvertOwnedUTXOIntoVin(utxo)
-		fullVinSecretKey := bip352.AddPrivateKeys(*vin.SecretKey, [32]byte(w.SpendSecret))
-		vin.SecretKey = &fullVinSecretKey
-		vins[i] = &vin
+	vins := w.spendableVins(selection.Inputs)
+
+	finalTx, recipients, err := buildSignedTx(recipients, vins, chainParams)
+	if err != nil {
+		return nil, Selection{}, nil, err
 	}
 
-	// now we need the difference between the inputs and outputs so that we can assign a value for change
-	var sumAllInputs uint64
-	for _, vin := range vins {
-		sumAllInputs += vin.Amount
+	// a changeless selection may drop the cost of change or a leftover too small for change to the fee
+	err = checkFeeRate(finalTx, selection.Inputs, feeRate, maxDroppedExcess(feeRate, minChangeAmount))
+	if err != nil {
+		return nil, Selection{}, nil, err
+	}
+
+	// the change recipient is the only one paying to our change address
+	var changeScript []byte
+	changeAddress := w.ChangeAddress()
+	for _, recipient := range recipients {
+		if recipient.GetAddress() == changeAddress {
+			changeScript = recipient.GetPkScript()
+		}
+	}
+
+	return finalTx, selection, changeScript, nil
+}
+
+// selectCoins runs the coin selection and appends the change output to the recipients if there is change
+func (w Wallet) selectCoins(
+	recipients []Recipient,
+	utxos scanwallet.UtxoCollection,
+	feeRate FeeRate,
+	chainParams *chaincfg.Params,
+	minChangeAmount uint64,
+	coinControl CoinControl,
+) (
+	Selection,
+	[]Recipient,
+	error,
+) {
+	if err := validateSubtractFeeFrom(recipients, coinControl.SubtractFeeFrom); err != nil {
+		return Selection{}, nil, err
 	}
 
-	if changeAmount > 0 {
+	// coins given explicitly are all spent
+	spendAll := len(coinControl.Only) > 0
+	subtractFee := len(coinControl.SubtractFeeFrom) > 0
+	selector, err := NewCoinSelector(coinControl.Strategy, utxos, minChangeAmount, recipients, chainParams, spendAll, subtractFee)
+	if err != nil {
+		return Selection{}, nil, err
+	}
+
+	selection, err := selector.Select(feeRate)
+	if err != nil {
+		return Selection{}, nil, err
+	}
+
+	if subtractFee {
+		// the excess a changeless selection dropped already pays part of the fee
+		var sumInputs, sumRecipients uint64
+		for _, utxo := range selection.Inputs {
+			sumInputs += utxo.Amount
+		}
+		for _, recipient := range recipients {
+			sumRecipients += recipient.GetAmount()
+		}
+		dropped := sumInputs - sumRecipients - selection.Change
+		recipients, err = subtractFeeFromRecipients(recipients, coinControl.SubtractFeeFrom, selection.Fee-min(selection.Fee, dropped))
+		if err != nil {
+			return Selection{}, nil, err
+		}
+	}
+
+	if selection.Change > 0 {
 		// change exists, and it should be greater than the MinChangeAmount
 		recipients = append(recipients, &RecipientImpl{
 			Address: w.ChangeAddress(),
-			Amount:  changeAmount,
+			Amount:  selection.Change,
 		})
 	}
 
-	// extract the ScriptPubKeys of the SP recipients with the selected txInputs
-	recipients, err = ParseRecipients(recipients, vins, chainParams)
+	return selection, recipients, nil
+}
+
+// ErrRecipientBelowDust is returned if a recipient paying the fee would receive less than DustLimit
+var ErrRecipientBelowDust = fmt.Errorf("recipient amount after the fee is below the dust limit")
+
+func validateSubtractFeeFrom(recipients []Recipient, indices []int) error {
+	seen := make(map[int]bool, len(indices))
+	for _, i := range indices {
+		if i < 0 || i >= len(recipients) {
+			return fmt.Errorf("no recipient %d to subtract the fee from, there are %d", i, len(recipients))
+		}
+		if seen[i] {
+			return fmt.Errorf("recipient %d is given twice to subtract the fee from", i)
+		}
+		seen[i] = true
+	}
+	return nil
+}
+
+// subtractFeeFromRecipients splits the fee evenly between the recipients at the indices,
+// the first one also pays the remainder. The given recipients are not modified.
+// SP outputs are derived later in ParseRecipients, their amount does not change the output key.
+func subtractFeeFromRecipients(recipients []Recipient, indices []int, fee uint64) ([]Recipient, error) {
+	share := fee / uint64(len(indices))
+	remainder := fee % uint64(len(indices))
+
+	result := slices.Clone(recipients)
+	for j, i := range indices {
+		pays := share
+		if j == 0 {
+			pays += remainder
+		}
+
+		amount := recipients[i].GetAmount()
+		if amount < pays || amount-pays < DustLimit {
+			return nil, fmt.Errorf("%w: %s would get %d sats after paying %d sats",
+				ErrRecipientBelowDust, recipients[i].GetAddress(), int64(amount)-int64(pays), pays)
+		}
+
+		result[i] = &RecipientImpl{
+			Address:  recipients[i].GetAddress(),
+			Amount:   amount - pays,
+			PkScript: recipients[i].GetPkScript(),
+		}
+	}
+
+	return result, nil
+}
+
+// Sweep spends all given utxos to a single address without a change output.
+// The fee is subtracted from the swept amount.
+func (w Wallet) Sweep(
+	utxos scanwallet.UtxoCollection,
+	address string,
+	feeRate FeeRate,
+	chainParams *chaincfg.Params,
+) (
+	[]byte,
+	error,
+) {
+	finalTx, _, err := w.sweepTx(utxos, address, feeRate, chainParams)
 	if err != nil {
 		return nil, err
 	}
 
-	err = sanityCheckRecipientsForSending(recipients)
+	var buf bytes.Buffer
+	err = finalTx.Serialize(&buf)
 	if err != nil {
 		return nil, err
 	}
 
+	return buf.Bytes(), nil
+}
+
+// SweepTo spends all coins allowed by the coin control to a single address, regular or silent payment,
+// with the fee subtracted and no change output. Like SendToRecipients the transaction is recorded as pending.
+func SweepTo(
+	walletData *WalletData,
+	address string,
+	feeRate FeeRate,
+	coinControl CoinControl,
+) (
+	[]byte,
+	Selection,
+	error,
+) {
+	chainParams, err := walletData.Wallet.Network.ChainParams()
+	if err != nil {
+		return nil, Selection{}, err
+	}
+
+	utxos, err := walletData.selectableUTXOs(coinControl)
+	if err != nil {
+		return nil, Selection{}, err
+	}
+
+	finalTx, selection, err := walletData.Wallet.sweepTx(utxos, address, feeRate, chainParams)
+	if err != nil {
+		return nil, Selection{}, err
+	}
+
+	var buf bytes.Buffer
+	err = finalTx.Serialize(&buf)
+	if err != nil {
+		return nil, Selection{}, err
+	}
+
+	walletData.addPendingTx(finalTx, utxos, nil)
+
+	return buf.Bytes(), selection, nil
+}
+
+// SelectSweep returns the selection of SweepTo without building or signing anything
+func SelectSweep(
+	walletData *WalletData,
+	address string,
+	feeRate FeeRate,
+	coinControl CoinControl,
+) (
+	Selection,
+	error,
+) {
+	chainParams, err := walletData.Wallet.Network.ChainParams()
+	if err != nil {
+		return Selection{}, err
+	}
+
+	utxos, err := walletData.selectableUTXOs(coinControl)
+	if err != nil {
+		return Selection{}, err
+	}
+
+	selection, _, err := sweepSelection(utxos, address, feeRate, chainParams)
+	return selection, err
+}
+
+func (w Wallet) sweepTx(
+	utxos scanwallet.UtxoCollection,
+	address string,
+	feeRate FeeRate,
+	chainParams *chaincfg.Params,
+) (
+	*wire.MsgTx,
+	Selection,
+	error,
+) {
+	if w.IsWatchOnly() {
+		return nil, Selection{}, ErrWatchOnly
+	}
+
+	selection, recipient, err := sweepSelection(utxos, address, feeRate, chainParams)
+	if err != nil {
+		return nil, Selection{}, err
+	}
+
+	finalTx, _, err := buildSignedTx([]Recipient{recipient}, w.spendableVins(utxos), chainParams)
+	if err != nil {
+		return nil, Selection{}, err
+	}
+
+	err = checkFeeRate(finalTx, utxos, feeRate, 0)
+	if err != nil {
+		return nil, Selection{}, err
+	}
+
+	return finalTx, selection, nil
+}
+
+// sweepSelection selects all utxos for a sweep and returns the single recipient,
+// it gets the sum of all utxos minus the fee
+func sweepSelection(
+	utxos scanwallet.UtxoCollection,
+	address string,
+	feeRate FeeRate,
+	chainParams *chaincfg.Params,
+) (
+	Selection,
+	Recipient,
+	error,
+) {
+	if feeRate.IsZero() {
+		return Selection{}, nil, ErrInvalidFeeRate
+	}
+	if len(utxos) == 0 {
+		return Selection{}, nil, ErrInsufficientFunds
+	}
+
+	outputLens, err := extractPkScriptsFromRecipients(
+		[]Recipient{&RecipientImpl{Address: address}}, chainParams,
+	)
+	if err != nil {
+		return Selection{}, nil, err
+	}
+
+	var sumAllInputs uint64
+	for _, utxo := range utxos {
+		sumAllInputs += utxo.Amount
+	}
+
+	fee := NeededFeeAbsolutSats(EstimateVSize(len(utxos), outputLens), feeRate)
+	if sumAllInputs < fee+DustLimit {
+		return Selection{}, nil, ErrInsufficientFunds
+	}
+
+	recipient := &RecipientImpl{
+		Address: address,
+		Amount:  sumAllInputs - fee,
+	}
+	selection, err := newSelection(StrategySweep, utxos, 0, []Recipient{recipient}, chainParams, feeRate, false)
+	if err != nil {
+		return Selection{}, nil, err
+	}
+
+	return selection, recipient, nil
+}
+
+// spendableVins converts owned utxos into vins carrying the full secret key (spend secret + tweak).
+// Must not be called for watch-only wallets.
+func (w Wallet) spendableVins(utxos []*UTXO) []*bip352.Vin {
+	var vins = make([]*bip352.Vin, len(utxos))
+	for i, utxo := range utxos {
+		vin := ConvertOwnedUTXOIntoVin(utxo)
+		fullVinSecretKey := bip352.AddPrivateKeys(*vin.SecretKey, [32]byte(w.SpendSecret))
+		vin.SecretKey = &fullVinSecretKey
+		vins[i] = &vin
+	}
+	return vins
+}
+
+// buildSignedTx computes the SP outputs for the final vins, signs all inputs and extracts the final transaction.
+// The returned recipients contain the computed PkScripts.
+func buildSignedTx(
+	recipients []Recipient,
+	vins []*bip352.Vin,
+	chainParams *chaincfg.Params,
+) (
+	*wire.MsgTx,
+	[]Recipient,
+	error,
+) {
+	// extract the ScriptPubKeys of the SP recipients with the selected txInputs
+	recipients, err := ParseRecipients(recipients, vins, chainParams)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = sanityCheckRecipientsForSending(recipients)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	packet, err := CreateUnsignedPsbt(recipients, vins)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	err = SignPsbt(packet, vins)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	err = psbt.MaybeFinalizeAll(packet)
 	if err != nil {
-		panic(err) // todo remove panic
+		return nil, nil, fmt.Errorf("failed to finalize psbt: %w", err)
 	}
 
 	finalTx, err := psbt.Extract(packet)
 	if err != nil {
-		panic(err) // todo remove panic
+		return nil, nil, fmt.Errorf("failed to extract transaction: %w", err)
 	}
 
-	var sumAllOutputs uint64
-	for _, recipient := range recipients {
-		sumAllOutputs += recipient.GetAmount()
-	}
-	// vSize := mempool.GetTxVirtualSize(btcutil.NewTx(finalTx))
-	// actualFee := sumAllInputs - sumAllOutputs
-	// actualFeeRate := float64(actualFee) / float64(vSize)
-
-	// errorTerm := 0.25 // todo make variable
-	// if actualFeeRate > float64(feeRate)+errorTerm {
-	// 	err = fmt.Errorf("actual fee rate deviates to strong from desired fee rate: %f > %d", actualFeeRate, feeRate)
-	// 	return nil, err
-	// }
-	//
-	// if actualFeeRate < float64(feeRate)-errorTerm {
-	// 	err = fmt.Errorf("actual fee rate deviates to strong from desired fee rate: %f < %d", actualFeeRate, feeRate)
-	// 	return nil, err
-	// }
+	return finalTx, recipients, nil
+}
 
-	var buf bytes.Buffer
-	err = finalTx.Serialize(&buf)
-	if err != nil {
-		return nil, err
+// checkFeeRate compares the fee of the signed transaction with the fee rate on its real virtual size.
+// The fee must not be lower and may only exceed it by maxExcess sats.
+func checkFeeRate(tx *wire.MsgTx, inputs []*UTXO, feeRate FeeRate, maxExcess uint64) error {
+	var sumInputs, sumOutputs uint64
+	for _, utxo := range inputs {
+		sumInputs += utxo.Amount
+	}
+	for _, out := range tx.TxOut {
+		sumOutputs += uint64(out.Value)
+	}
+	if sumOutputs > sumInputs {
+		return fmt.Errorf("outputs (%d sats) exceed inputs (%d sats)", sumOutputs, sumInputs)
 	}
 
-	// if markSpent {
-	// 	var found int
-	// 	// now that everything worked mark as spent if desired
-	// 	for _, vin := range vins {
-	// 		vinOutpoint, err := utils.SerialiseVinToOutpoint(*vin)
-	// 		if err != nil {
-	// 			logging.ErrorLogger.Println(err)
-	// 			return nil, err
-	// 		}
-	// 		for _, utxo := range d.Wallet.UTXOs {
-	// 			utxoOutpoint, err := utxo.SerialiseToOutpoint()
-	// 			if err != nil {
-	// 				logging.ErrorLogger.Println(err)
-	// 				return nil, err
-	// 			}
-	// 			if bytes.Equal(vinOutpoint[:], utxoOutpoint[:]) {
-	// 				utxo.State = src.StateUnconfirmedSpent
-	// 				found++
-	// 				logging.DebugLogger.Printf("Marked %x as spent\n", utxoOutpoint)
-	// 			}
-	// 		}
-	// 	}
-	// 	if found != len(vins) {
-	// 		err = fmt.Errorf("we could not mark enough utxos as spent. marked %d, needed %d", found, len(vins))
-	// 		return nil, err
-	// 	}
-	// }
-
-	return buf.Bytes(), err
+	vSize := mempool.GetTxVirtualSize(btcutil.NewTx(tx))
+	fee := sumInputs - sumOutputs
+	needed := feeRate.Fee(vSize)
+
+	if fee < needed || fee > needed+maxExcess {
+		return fmt.Errorf(
+			"actual fee rate deviates from the target: %d sats for %d vB is %s, needed %d sats for %s",
+			fee, vSize, FeeRateOf(fee, vSize), needed, feeRate,
+		)
+	}
+
+	return nil
 }
 
 // Taken from blindbitd
@@ -377,12 +711,12 @@ func SignPsbt(packet *psbt.Packet, vins []*bip352.Vin) error {
 	for iOuter, input := range packet.UnsignedTx.TxIn {
 		signatureHash, err := txscript.CalcTaprootSignatureHash(sigHashes, txscript.SigHashDefault, packet.UnsignedTx, iOuter, multiFetcher)
 		if err != nil {
-			panic(err)
+			return fmt.Errorf("failed to compute signature hash: %w", err)
 		}
 
 		pInput, err := matchAndSign(input, signatureHash, vins)
 		if err != nil {
-			panic(err)
+			return err
 		}
 
 		pInputs = append(pInputs, pInput)
@@ -415,13 +749,13 @@ func matchAndSign(
 			}
 			signature, err := schnorr.Sign(privKey, signatureHash)
 			if err != nil {
-				panic(err)
+				return psbtInput, fmt.Errorf("failed to sign input: %w", err)
 			}
 
 			var witnessBytes bytes.Buffer
 			err = psbt.WriteTxWitness(&witnessBytes, [][]byte{signature.Serialize()})
 			if err != nil {
-				panic(err)
+				return psbtInput, fmt.Errorf("failed to write witness: %w", err)
 			}
 
 			return psbt.PInput{
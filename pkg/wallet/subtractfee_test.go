@@ -0,0 +1,175 @@
+package wallet
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/mempool"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubtractFeeFromRecipients(t *testing.T) {
+	recipients := func() []Recipient {
+		return []Recipient{
+			&RecipientImpl{Address: "a", Amount: 10_000},
+			&RecipientImpl{Address: "b", Amount: 20_000},
+			&RecipientImpl{Address: "c", Amount: 1_000},
+		}
+	}
+
+	for _, tc := range []struct {
+		name     string
+		indices  []int
+		fee      uint64
+		expected []uint64
+		err      error
+	}{
+		{name: "one recipient", indices: []int{1}, fee: 301, expected: []uint64{10_000, 19_699, 1_000}},
+		{name: "split evenly, the first pays the remainder", indices: []int{1, 0}, fee: 301, expected: []uint64{9_850, 19_849, 1_000}},
+		{name: "exactly dust is allowed", indices: []int{2}, fee: 454, expected: []uint64{10_000, 20_000, 546}},
+		{name: "below dust", indices: []int{2}, fee: 455, err: ErrRecipientBelowDust},
+		{name: "fee above the amount", indices: []int{2}, fee: 2_000, err: ErrRecipientBelowDust},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			given := recipients()
+			result, err := subtractFeeFromRecipients(given, tc.indices, tc.fee)
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+
+			var amounts []uint64
+			for _, r := range result {
+				amounts = append(amounts, r.GetAmount())
+			}
+			assert.Equal(t, tc.expected, amounts)
+			// the given recipients are left alone
+			assert.Equal(t, recipients(), given)
+		})
+	}
+
+	assert.Error(t, validateSubtractFeeFrom(recipients(), []int{3}))
+	assert.Error(t, validateSubtractFeeFrom(recipients(), []int{-1}))
+	assert.Error(t, validateSubtractFeeFrom(recipients(), []int{0, 0}))
+	assert.NoError(t, validateSubtractFeeFrom(recipients(), []int{0, 2}))
+}
+
+func TestSendToRecipients_SubtractFee(t *testing.T) {
+	full, _ := testWatchOnlyPair(t)
+	other, err := New("", NetworkSignet)
+	require.NoError(t, err)
+	regular, err := btcutil.NewAddressTaproot(make([]byte, 32), &chaincfg.SigNetParams)
+	require.NoError(t, err)
+	regularScript, err := txscript.PayToAddrScript(regular)
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		name     string
+		indices  []int
+		strategy CoinSelectionStrategy
+	}{
+		{name: "silent payment recipient pays", indices: []int{0}},
+		{name: "regular recipient pays", indices: []int{1}, strategy: StrategyLargestFirst},
+		{name: "both pay", indices: []int{0, 1}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			d := NewWalletData(full)
+			for _, u := range testOwnedUTXOs(t, full, 30_000, 30_000) {
+				d.UTXOs = append(d.UTXOs, *u)
+			}
+
+			recipients := []Recipient{
+				&RecipientImpl{Address: other.ChangeAddress(), Amount: 20_000},
+				&RecipientImpl{Address: regular.String(), Amount: 15_000},
+			}
+			cc := CoinControl{Strategy: tc.strategy, SubtractFeeFrom: tc.indices}
+			txBytes, _, err := SendToRecipients(d, recipients, SatPerVByte(2), cc)
+			require.NoError(t, err)
+
+			var tx wire.MsgTx
+			require.NoError(t, tx.Deserialize(bytes.NewReader(txBytes)))
+			require.Len(t, tx.TxIn, 2)
+			require.Len(t, tx.TxOut, 3)
+			fee := SatPerVByte(2).Fee(mempool.GetTxVirtualSize(btcutil.NewTx(&tx)))
+
+			// the change gets everything the recipients don't, the fee is not on top
+			var sumOutputs uint64
+			for _, out := range tx.TxOut {
+				sumOutputs += uint64(out.Value)
+			}
+			assert.Equal(t, 60_000-fee, sumOutputs)
+			assert.EqualValues(t, 60_000-35_000, d.PendingTxs[0].ChangeAmount)
+
+			var regularAmount uint64
+			for _, out := range tx.TxOut {
+				if bytes.Equal(out.PkScript, regularScript) {
+					regularAmount = uint64(out.Value)
+				}
+			}
+			spAmount := sumOutputs - regularAmount - d.PendingTxs[0].ChangeAmount
+			assert.Equal(t, 35_000-fee, spAmount+regularAmount)
+			if len(tc.indices) == 1 && tc.indices[0] == 1 {
+				assert.EqualValues(t, 20_000, spAmount)
+			} else if len(tc.indices) == 1 {
+				assert.EqualValues(t, 15_000, regularAmount)
+			}
+		})
+	}
+
+	// the fee would leave the recipient with dust
+	d := NewWalletData(full)
+	for _, u := range testOwnedUTXOs(t, full, 30_000) {
+		d.UTXOs = append(d.UTXOs, *u)
+	}
+	recipients := []Recipient{&RecipientImpl{Address: other.ChangeAddress(), Amount: 600}}
+	_, _, err = SendToRecipients(d, recipients, SatPerVByte(2), CoinControl{SubtractFeeFrom: []int{0}})
+	assert.ErrorIs(t, err, ErrRecipientBelowDust)
+	assert.Empty(t, d.PendingTxs)
+}
+
+func TestSendToRecipients_SubtractFeeBudget(t *testing.T) {
+	full, _ := testWatchOnlyPair(t)
+	regular, err := btcutil.NewAddressTaproot(make([]byte, 32), &chaincfg.SigNetParams)
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		name     string
+		coin     uint64
+		strategy CoinSelectionStrategy
+	}{
+		{name: "exact budget bnb", coin: 100_000, strategy: StrategyBnB},
+		{name: "exact budget largest-first", coin: 100_000, strategy: StrategyLargestFirst},
+		{name: "excess below dust bnb", coin: 100_200, strategy: StrategyBnB},
+		{name: "excess below dust largest-first", coin: 100_200, strategy: StrategyLargestFirst},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			d := NewWalletData(full)
+			for _, u := range testOwnedUTXOs(t, full, tc.coin) {
+				d.UTXOs = append(d.UTXOs, *u)
+			}
+
+			recipients := []Recipient{&RecipientImpl{Address: regular.String(), Amount: 100_000}}
+			cc := CoinControl{Strategy: tc.strategy, SubtractFeeFrom: []int{0}}
+			txBytes, selection, err := SendToRecipients(d, recipients, SatPerVByte(2), cc)
+			require.NoError(t, err)
+
+			var tx wire.MsgTx
+			require.NoError(t, tx.Deserialize(bytes.NewReader(txBytes)))
+			require.Len(t, tx.TxIn, 1)
+			// no change output, the excess goes to the fee and the recipient pays the rest
+			require.Len(t, tx.TxOut, 1)
+			assert.Zero(t, selection.Change)
+			assert.Zero(t, d.PendingTxs[0].ChangeAmount)
+
+			needed := SatPerVByte(2).Fee(mempool.GetTxVirtualSize(btcutil.NewTx(&tx)))
+			assert.EqualValues(t, min(100_000, tc.coin-needed), tx.TxOut[0].Value)
+			assert.EqualValues(t, max(needed, tc.coin-100_000), selection.Fee)
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package wallet
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+	scanwallet "github.com/setavenger/blindbit-scan/pkg/wallet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSweepTo(t *testing.T) {
+	full, _ := testWatchOnlyPair(t)
+	other, err := New("", NetworkSignet)
+	require.NoError(t, err)
+	regular, err := btcutil.NewAddressTaproot(make([]byte, 32), &chaincfg.SigNetParams)
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		name    string
+		address string
+		cc      func(d *WalletData) CoinControl
+		inputs  int
+	}{
+		{name: "regular address", address: regular.String(), inputs: 3},
+		{name: "silent payment address", address: other.ChangeAddress(), inputs: 3},
+		{name: "selected coins", address: other.ChangeAddress(), inputs: 1, cc: func(d *WalletData) CoinControl {
+			return CoinControl{Only: []string{FormatOutpoint(d.UTXOs[1].Txid, d.UTXOs[1].Vout)}}
+		}},
+		{name: "excluded coin", address: regular.String(), inputs: 2, cc: func(d *WalletData) CoinControl {
+			return CoinControl{Exclude: []string{FormatOutpoint(d.UTXOs[1].Txid, d.UTXOs[1].Vout)}}
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			d := NewWalletData(full)
+			for _, u := range testOwnedUTXOs(t, full, 10_000, 20_000, 30_000) {
+				d.UTXOs = append(d.UTXOs, *u)
+			}
+			var cc CoinControl
+			if tc.cc != nil {
+				cc = tc.cc(d)
+			}
+
+			txBytes, _, err := SweepTo(d, tc.address, SatPerVByte(2), cc)
+			require.NoError(t, err)
+			var tx wire.MsgTx
+			require.NoError(t, tx.Deserialize(bytes.NewReader(txBytes)))
+
+			require.Len(t, tx.TxIn, tc.inputs)
+			require.Len(t, tx.TxOut, 1)
+			require.Len(t, d.PendingTxs, 1)
+			assert.Empty(t, d.PendingTxs[0].Change)
+
+			var spent uint64
+			for _, u := range d.UTXOs {
+				if u.State == scanwallet.StateUnconfirmedSpent {
+					spent += u.Amount
+				}
+			}
+			fee := spent - uint64(tx.TxOut[0].Value)
+			assert.Equal(t, NeededFeeAbsolutSats(EstimateVSize(tc.inputs, []int{ScriptPubKeyTaprootLen}), SatPerVByte(2)), fee)
+		})
+	}
+}
+
+func TestCreateUnsignedSweep(t *testing.T) {
+	full, watchOnly := testWatchOnlyPair(t)
+	regular, err := btcutil.NewAddressTaproot(make([]byte, 32), &chaincfg.SigNetParams)
+	require.NoError(t, err)
+
+	d := NewWalletData(watchOnly)
+	for _, u := range testOwnedUTXOs(t, full, 10_000, 20_000, 30_000) {
+		d.UTXOs = append(d.UTXOs, *u)
+	}
+
+	// an own silent payment address works without the spend secret, other ones don't
+	for _, address := range []string{regular.String(), watchOnly.ChangeAddress()} {
+		packet, _, err := CreateUnsignedSweep(d, address, SatPerVByte(2), CoinControl{})
+		require.NoError(t, err)
+		require.Len(t, packet.UnsignedTx.TxIn, 3)
+		require.Len(t, packet.UnsignedTx.TxOut, 1)
+
+		tx, err := full.SignUnsignedTx(packet)
+		require.NoError(t, err)
+		require.NoError(t, checkFeeRate(tx, testOwnedUTXOs(t, full, 10_000, 20_000, 30_000), SatPerVByte(2), 0))
+	}
+	assert.Empty(t, d.PendingTxs)
+
+	other, err := New("", NetworkSignet)
+	require.NoError(t, err)
+	_, _, err = CreateUnsignedSweep(d, other.ChangeAddress(), SatPerVByte(2), CoinControl{})
+	assert.ErrorIs(t, err, ErrWatchOnlyForeignSP)
+}
@@ -34,33 +34,51 @@ const (
 
 // Wallet represents the core wallet data
 type Wallet struct {
-	Network     Network   `json:"network"`
-	Mnemonic    string    `json:"mnemonic"`
-	ScanSecret  []byte    `json:"scan_secret"`
-	SpendSecret []byte    `json:"spend_secret"`
+	Network  Network `json:"network"`
+	Mnemonic string  `json:"mnemonic"`
+	// HasPassphrase records that a BIP39 passphrase was used, the passphrase itself is never stored
+	HasPassphrase bool   `json:"has_passphrase,omitempty"`
+	ScanSecret    []byte `json:"scan_secret"`
+	SpendSecret   []byte `json:"spend_secret"`
+	// SpendPublic is only set for watch-only wallets which have no spend secret
+	SpendPublic []byte    `json:"spend_public,omitempty"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 }
 
 // WalletData represents the complete wallet data stored on disk
 type WalletData struct {
-	Wallet     Wallet  `json:"wallet"`
-	UTXOs      []UTXO  `json:"utxos"`
-	LastHeight int64   `json:"last_height"`
-	Labels     []Label `json:"labels"`
+	// Version of the on-disk schema, see CurrentVersion and migrations
+	Version    int    `json:"version"`
+	Wallet     Wallet `json:"wallet"`
+	UTXOs      []UTXO `json:"utxos"`
+	LastHeight int64  `json:"last_height"`
+	// BirthHeight is the chain tip when the wallet was created, no coins can exist below it. 0 if unknown.
+	BirthHeight int64   `json:"birth_height"`
+	Labels      []Label `json:"labels"`
+	// PendingTxs are sent transactions not yet seen as mined by the scan daemon
+	PendingTxs []PendingTx `json:"pending_txs,omitempty"`
+	// History is the transaction log, see TxRecord
+	History []TxRecord `json:"history,omitempty"`
+	// Frozen are the outpoints (txid:vout) coin selection must not use
+	Frozen []string `json:"frozen,omitempty"`
 }
 
 // ScanOnlyParams represents the parameters needed for scan-only wallets
 type ScanOnlyParams struct {
-	ScanSecret []byte  `json:"scan_secret"`
-	Network    Network `json:"network"`
+	ScanSecret  []byte  `json:"scan_secret"`
+	SpendPublic []byte  `json:"spend_public"`
+	Network     Network `json:"network"`
 }
 
 // UTXO represents a UTXO in the wallet
 type UTXO = scanwallet.OwnedUTXO
 
-// Label represents a labeled address
-type Label = bip352.Label
+// Label represents a labeled address handed out by the wallet, Name is what the user calls it
+type Label struct {
+	bip352.Label
+	Name string `json:"name,omitempty"`
+}
 
 // NewWallet creates a new wallet with the given mnemonic and network
 func NewWallet(mnemonic string, network Network) (*Wallet, error) {
@@ -81,10 +99,12 @@ func (w *Wallet) MarshalJSON() ([]byte, error) {
 		*Alias
 		ScanSecret  string `json:"scan_secret"`
 		SpendSecret string `json:"spend_secret"`
+		SpendPublic string `json:"spend_public,omitempty"`
 	}{
 		Alias:       (*Alias)(w),
 		ScanSecret:  hex.EncodeToString(w.ScanSecret),
 		SpendSecret: hex.EncodeToString(w.SpendSecret),
+		SpendPublic: hex.EncodeToString(w.SpendPublic),
 	})
 }
 
@@ -95,6 +115,7 @@ func (w *Wallet) UnmarshalJSON(data []byte) (err error) {
 		*Alias
 		ScanSecret  string `json:"scan_secret"`
 		SpendSecret string `json:"spend_secret"`
+		SpendPublic string `json:"spend_public,omitempty"`
 	}{
 		Alias: (*Alias)(w),
 	}
@@ -111,6 +132,11 @@ func (w *Wallet) UnmarshalJSON(data []byte) (err error) {
 		return fmt.Errorf("failed to decode spend secret: %w", err)
 	}
 
+	w.SpendPublic, err = hex.DecodeString(aux.SpendPublic)
+	if err != nil {
+		return fmt.Errorf("failed to decode spend public key: %w", err)
+	}
+
 	return nil
 }
 
@@ -119,11 +145,24 @@ func (w Wallet) PubKeyScan() [33]byte {
 	return bip352.ConvertToFixedLength33(scanPubKey.SerializeCompressed())
 }
 
+// IsWatchOnly reports whether the wallet lacks the spend secret and can't sign
+func (w Wallet) IsWatchOnly() bool {
+	return len(w.SpendSecret) == 0
+}
+
 func (w Wallet) PubKeySpend() [33]byte {
+	if w.IsWatchOnly() {
+		return bip352.ConvertToFixedLength33(w.SpendPublic)
+	}
 	_, spendPubKey := btcec.PrivKeyFromBytes(w.SpendSecret)
 	return bip352.ConvertToFixedLength33(spendPubKey.SerializeCompressed())
 }
 
+// Address returns the unlabeled silent payment address of the wallet
+func (w Wallet) Address() (string, error) {
+	return bip352.CreateAddress(w.PubKeyScan(), w.PubKeySpend(), w.Network == NetworkMainnet, 0)
+}
+
 func (w Wallet) ChangeAddress() string {
 	label, err := GenerateLabel(w, 0)
 	if err != nil {
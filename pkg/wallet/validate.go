@@ -0,0 +1,74 @@
+package wallet
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/setavenger/go-bip352"
+)
+
+// Validate checks that the wallet data is internally consistent:
+// the keys match the mnemonic, every UTXO belongs to the keys and every label matches the scan secret.
+// Used before restoring wallet data that did not come from this datadir.
+func (d *WalletData) Validate() error {
+	w := d.Wallet
+
+	if _, err := w.Network.ChainParams(); err != nil {
+		return err
+	}
+
+	if len(w.ScanSecret) != 32 {
+		return fmt.Errorf("scan secret must be 32 bytes, got %d", len(w.ScanSecret))
+	}
+
+	if w.IsWatchOnly() {
+		if w.Mnemonic != "" {
+			return fmt.Errorf("wallet has a mnemonic but no spend secret")
+		}
+		if _, err := btcec.ParsePubKey(w.SpendPublic); err != nil {
+			return fmt.Errorf("invalid spend public key: %w", err)
+		}
+	} else {
+		if len(w.SpendSecret) != 32 {
+			return fmt.Errorf("spend secret must be 32 bytes, got %d", len(w.SpendSecret))
+		}
+		if len(w.SpendPublic) > 0 {
+			_, spendPub := btcec.PrivKeyFromBytes(w.SpendSecret)
+			if !bytes.Equal(spendPub.SerializeCompressed(), w.SpendPublic) {
+				return fmt.Errorf("spend public key does not match the spend secret")
+			}
+		}
+	}
+
+	// keys derived with a passphrase can't be checked, the passphrase is never stored
+	if _, err := w.UsesLegacyDerivation(); err != nil {
+		if errors.Is(err, ErrUnknownDerivation) {
+			return fmt.Errorf("keys do not match the mnemonic")
+		}
+		return err
+	}
+
+	for _, u := range d.UTXOs {
+		pubKey, err := w.OutputPubKey(u.PrivKeyTweak)
+		if err != nil {
+			return fmt.Errorf("failed to derive key for utxo %x:%d: %w", u.Txid, u.Vout, err)
+		}
+		if !bytes.Equal(pubKey.SerializeCompressed()[1:], u.PubKey[:]) {
+			return fmt.Errorf("utxo %x:%d does not belong to the wallet keys", u.Txid, u.Vout)
+		}
+	}
+
+	for _, l := range d.Labels {
+		expected, err := bip352.CreateLabel([32]byte(w.ScanSecret), l.M)
+		if err != nil {
+			return fmt.Errorf("failed to create label %d: %w", l.M, err)
+		}
+		if expected.Tweak != l.Tweak {
+			return fmt.Errorf("label %d does not match the scan secret", l.M)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,52 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWalletData_Validate(t *testing.T) {
+	full, watchOnly := testWatchOnlyPair(t)
+	other, err := New("", NetworkSignet)
+	require.NoError(t, err)
+
+	label, err := GenerateLabel(*full, 1)
+	require.NoError(t, err)
+	otherLabel, err := GenerateLabel(*other, 1)
+	require.NoError(t, err)
+
+	valid := func(w *Wallet) *WalletData {
+		d := NewWalletData(w)
+		for _, u := range testOwnedUTXOs(t, full, 10_000, 20_000) {
+			d.UTXOs = append(d.UTXOs, *u)
+		}
+		d.Labels = append(d.Labels, Label{Label: label, Name: "test"})
+		return d
+	}
+
+	for _, tc := range []struct {
+		name   string
+		modify func(d *WalletData)
+		err    bool
+	}{
+		{name: "full", modify: func(d *WalletData) {}},
+		{name: "watch-only", modify: func(d *WalletData) { d.Wallet = *watchOnly }},
+		{name: "unknown network", modify: func(d *WalletData) { d.Wallet.Network = "litecoin" }, err: true},
+		{name: "keys do not match mnemonic", modify: func(d *WalletData) { d.Wallet.Mnemonic = other.Mnemonic }, err: true},
+		{name: "foreign utxo", modify: func(d *WalletData) { d.UTXOs[1].PubKey[0] ^= 0xff }, err: true},
+		{name: "foreign label", modify: func(d *WalletData) { d.Labels[0].Label = otherLabel }, err: true},
+		{name: "short spend secret", modify: func(d *WalletData) { d.Wallet.SpendSecret = d.Wallet.SpendSecret[:31] }, err: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			d := valid(full)
+			tc.modify(d)
+			if tc.err {
+				assert.Error(t, d.Validate())
+			} else {
+				assert.NoError(t, d.Validate())
+			}
+		})
+	}
+}
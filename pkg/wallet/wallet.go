@@ -1,12 +1,7 @@
 package wallet
 
 import (
-	"encoding/json"
 	"fmt"
-	"os"
-	"os/user"
-	"path/filepath"
-	"strings"
 	"time"
 
 	"github.com/btcsuite/btcd/btcutil/hdkeychain"
@@ -14,33 +9,15 @@ import (
 	"github.com/tyler-smith/go-bip39"
 )
 
-// expandPath expands the path to include the home directory if the path
-// is prefixed with '~'. It also handles environment variables.
-func expandPath(path string) string {
-	if len(path) == 0 {
-		return path
-	}
-
-	if path[0] == '~' {
-		home, err := user.Current()
-		if err != nil {
-			return path
-		}
-		path = strings.Replace(path, "~", home.HomeDir, 1)
-	}
-
-	return os.ExpandEnv(path)
-}
-
-// New creates a new wallet with a random seed phrase and stores it in the datadir
+// New creates a new wallet with a random seed phrase.
+// An optional BIP39 passphrase can be given, it is never stored.
+// Persisting the wallet is up to the caller, see pkg/storage.
 func New(
-	datadir string,
+	passphrase string,
 	network Network,
 ) (
 	*Wallet, error,
 ) {
-	expandedDatadir := expandPath(datadir)
-
 	entropy, err := bip39.NewEntropy(256)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate entropy: %w", err)
@@ -52,171 +29,90 @@ func New(
 		return nil, fmt.Errorf("failed to generate mnemonic: %w", err)
 	}
 
-	params, ok := networkParams[network]
-	if !ok {
-		return nil, fmt.Errorf("unsupported network: %s", network)
-	}
-
-	master, err := hdkeychain.NewMaster(entropy, params)
-	if err != nil {
-		return nil, err
-	}
-
-	scanSecret, spendSecret, err := bip352.DeriveKeysFromMaster(master, network == NetworkMainnet)
-	if err != nil {
-		return nil, fmt.Errorf("failed to derive keys: %w", err)
-	}
-
-	// Create wallet instance
-	w := &Wallet{
-		Network:     network,
-		Mnemonic:    mnemonic,
-		ScanSecret:  scanSecret[:],
-		SpendSecret: spendSecret[:],
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
-	}
-
-	// Create wallet data with empty UTXOs and labels
-	data := &WalletData{
-		Wallet:     *w,
-		UTXOs:      []UTXO{},
-		Labels:     []Label{},
-		LastHeight: 0,
-	}
-
-	// Ensure datadir exists
-	if err := os.MkdirAll(expandedDatadir, 0700); err != nil {
-		return nil, fmt.Errorf("failed to create datadir: %w", err)
-	}
-
-	// Store wallet data
-	walletFile := filepath.Join(expandedDatadir, "wallet.json")
-	jsonData, err := json.MarshalIndent(data, "", "  ")
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal wallet data: %w", err)
-	}
-
-	if err := os.WriteFile(walletFile, jsonData, 0600); err != nil {
-		return nil, fmt.Errorf("failed to write wallet file: %w", err)
-	}
-
-	return w, nil
+	return fromMnemonic(mnemonic, passphrase, network)
 }
 
-// Import creates a wallet from an existing mnemonic and stores it in the datadir
+// Import creates a wallet from an existing mnemonic and optional BIP39 passphrase.
+// The passphrase is never stored, a different passphrase yields a different wallet.
+// Persisting the wallet is up to the caller, see pkg/storage.
 func Import(
-	datadir string,
 	mnemonic string,
+	passphrase string,
 	network Network,
 ) (
 	*Wallet, error,
 ) {
-	expandedDatadir := expandPath(datadir)
-
 	// Validate mnemonic
 	if !bip39.IsMnemonicValid(mnemonic) {
 		return nil, fmt.Errorf("invalid mnemonic")
 	}
 
-	// Generate seed from mnemonic
-	seed := bip39.NewSeed(mnemonic, "")
-
-	// Use first 32 bytes of seed for spend key, next 32 bytes for scan key
-	if len(seed) < 64 {
-		return nil, fmt.Errorf("seed too short")
-	}
+	return fromMnemonic(mnemonic, passphrase, network)
+}
 
-	params, ok := networkParams[network]
-	if !ok {
-		return nil, fmt.Errorf("unsupported network: %s", network)
-	}
+// fromMnemonic builds a wallet with keys derived from the standard BIP39 seed.
+// New and Import both go through here so a mnemonic always restores the same keys.
+func fromMnemonic(
+	mnemonic, passphrase string,
+	network Network,
+) (
+	*Wallet, error,
+) {
+	seed := bip39.NewSeed(mnemonic, passphrase)
 
-	master, err := hdkeychain.NewMaster(seed, params)
+	scanSecret, spendSecret, err := deriveKeysFromSeed(seed, network)
 	if err != nil {
 		return nil, err
 	}
 
-	scanSecret, spendSecret, err := bip352.DeriveKeysFromMaster(master, network == NetworkMainnet)
-	if err != nil {
-		return nil, fmt.Errorf("failed to derive keys: %w", err)
-	}
-
 	// Create wallet instance
 	w := &Wallet{
-		Network:     network,
-		Mnemonic:    mnemonic,
-		ScanSecret:  scanSecret[:],
-		SpendSecret: spendSecret[:],
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
-	}
-
-	// Create wallet data with empty UTXOs and labels
-	data := &WalletData{
-		Wallet:     *w,
-		UTXOs:      []UTXO{},
-		Labels:     []Label{},
-		LastHeight: 0,
-	}
-
-	// Ensure datadir exists
-	if err := os.MkdirAll(expandedDatadir, 0700); err != nil {
-		return nil, fmt.Errorf("failed to create datadir: %w", err)
-	}
-
-	// Store wallet data
-	walletFile := filepath.Join(expandedDatadir, "wallet.json")
-	jsonData, err := json.MarshalIndent(data, "", "  ")
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal wallet data: %w", err)
-	}
-
-	if err := os.WriteFile(walletFile, jsonData, 0600); err != nil {
-		return nil, fmt.Errorf("failed to write wallet file: %w", err)
+		Network:       network,
+		Mnemonic:      mnemonic,
+		HasPassphrase: passphrase != "",
+		ScanSecret:    scanSecret[:],
+		SpendSecret:   spendSecret[:],
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
 	}
 
 	return w, nil
 }
 
-// Load loads a wallet from the datadir
-func Load(datadir string) (*Wallet, error) {
-	expandedDatadir := expandPath(datadir)
-	walletFile := filepath.Join(expandedDatadir, "wallet.json")
-	data, err := os.ReadFile(walletFile)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read wallet file: %w", err)
-	}
-
-	var walletData WalletData
-	if err := json.Unmarshal(data, &walletData); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal wallet data: %w", err)
+// deriveKeysFromSeed derives the BIP352 scan and spend secrets from a BIP32 seed
+func deriveKeysFromSeed(
+	seed []byte,
+	network Network,
+) (
+	scanSecret, spendSecret [32]byte, err error,
+) {
+	params, ok := networkParams[network]
+	if !ok {
+		err = fmt.Errorf("unsupported network: %s", network)
+		return
 	}
 
-	return &walletData.Wallet, nil
-}
-
-// Save saves wallet data to the datadir
-func Save(datadir string, data *WalletData) error {
-	expandedDatadir := expandPath(datadir)
-
-	// Ensure datadir exists
-	if err := os.MkdirAll(expandedDatadir, 0700); err != nil {
-		return fmt.Errorf("failed to create datadir: %w", err)
+	master, err := hdkeychain.NewMaster(seed, params)
+	if err != nil {
+		return
 	}
 
-	// Store wallet data
-	walletFile := filepath.Join(expandedDatadir, "wallet.json")
-	jsonData, err := json.MarshalIndent(data, "", "  ")
+	scanSecret, spendSecret, err = bip352.DeriveKeysFromMaster(master, network == NetworkMainnet)
 	if err != nil {
-		return fmt.Errorf("failed to marshal wallet data: %w", err)
+		err = fmt.Errorf("failed to derive keys: %w", err)
 	}
+	return
+}
 
-	if err := os.WriteFile(walletFile, jsonData, 0600); err != nil {
-		return fmt.Errorf("failed to write wallet file: %w", err)
+// NewWalletData wraps a wallet into WalletData with empty UTXOs and labels
+func NewWalletData(w *Wallet) *WalletData {
+	return &WalletData{
+		Version:    CurrentVersion,
+		Wallet:     *w,
+		UTXOs:      []UTXO{},
+		Labels:     []Label{},
+		LastHeight: 0,
 	}
-
-	return nil
 }
 
 func GenerateLabel(
@@ -246,20 +142,3 @@ func GenerateLabel(
 	l.Address = address
 	return l, err
 }
-
-// LoadData loads the complete wallet data from the datadir
-func LoadData(datadir string) (*WalletData, error) {
-	expandedDatadir := expandPath(datadir)
-	walletFile := filepath.Join(expandedDatadir, "wallet.json")
-	data, err := os.ReadFile(walletFile)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read wallet file: %w", err)
-	}
-
-	var walletData WalletData
-	if err := json.Unmarshal(data, &walletData); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal wallet data: %w", err)
-	}
-
-	return &walletData, nil
-}
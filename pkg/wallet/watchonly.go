@@ -0,0 +1,493 @@
+package wallet
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	scanwallet "github.com/setavenger/blindbit-scan/pkg/wallet"
+	"github.com/setavenger/go-bip352"
+)
+
+/*
+Watch-only wallets only hold the scan secret and the spend public key (see ScanOnlyParams).
+That is enough to sync with blindbit-scan, verify ownership of found outputs and compute
+outputs to our own silent payment addresses (change) from the receiver side.
+Payments to foreign silent payment addresses need the input secret keys and can't be built.
+*/
+
+var (
+	ErrWatchOnly = fmt.Errorf("watch-only wallet can not sign transactions")
+	// ErrWatchOnlyForeignSP is returned if a watch-only wallet is asked to pay a silent payment address that is not its own
+	ErrWatchOnlyForeignSP = fmt.Errorf("watch-only wallets can only pay to regular addresses or their own silent payment addresses")
+)
+
+// psbtTweakKey is the proprietary PSBT input key (BIP174 0xFC type) carrying the BIP352 tweak of an input.
+// The signer adds it to its spend secret to get the key for the input, see Wallet.SignUnsignedTx.
+var psbtTweakKey = append([]byte{0xfc, 0x08}, append([]byte("blindbit"), 0x00)...)
+
+// NewWatchOnly creates a wallet from a scan secret and a spend public key, no mnemonic or spend secret is involved
+func NewWatchOnly(params ScanOnlyParams) (*Wallet, error) {
+	if _, ok := networkParams[params.Network]; !ok {
+		return nil, fmt.Errorf("unsupported network: %s", params.Network)
+	}
+
+	if len(params.ScanSecret) != 32 {
+		return nil, fmt.Errorf("scan secret must be 32 bytes, got %d", len(params.ScanSecret))
+	}
+	var scalar btcec.ModNScalar
+	if overflow := scalar.SetByteSlice(params.ScanSecret); overflow || scalar.IsZero() {
+		return nil, fmt.Errorf("invalid scan secret")
+	}
+
+	if len(params.SpendPublic) != 33 {
+		return nil, fmt.Errorf("spend public key must be 33 bytes compressed, got %d", len(params.SpendPublic))
+	}
+	if _, err := btcec.ParsePubKey(params.SpendPublic); err != nil {
+		return nil, fmt.Errorf("invalid spend public key: %w", err)
+	}
+
+	return &Wallet{
+		Network:     params.Network,
+		ScanSecret:  bytes.Clone(params.ScanSecret),
+		SpendPublic: bytes.Clone(params.SpendPublic),
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}, nil
+}
+
+// OutputPubKey computes the public key of an owned output from its tweak.
+// Works for full and watch-only wallets: B_spend + tweak*G.
+func (w Wallet) OutputPubKey(tweak [32]byte) (*btcec.PublicKey, error) {
+	if !w.IsWatchOnly() {
+		return DerivePublicKey(w.SpendSecret, tweak)
+	}
+
+	_, tweakPub := btcec.PrivKeyFromBytes(tweak[:])
+	sum, err := bip352.AddPublicKeys(w.PubKeySpend(), bip352.ConvertToFixedLength33(tweakPub.SerializeCompressed()))
+	if err != nil {
+		return nil, err
+	}
+
+	return btcec.ParsePubKey(sum[:])
+}
+
+// CreateUnsignedTx selects coins for the recipients and returns an unsigned PSBT instead of a signed transaction.
+// This is the send path for watch-only wallets.
+// Every input carries its witness utxo and its BIP352 tweak so an offline signer holding the spend secret can sign it.
+func CreateUnsignedTx(
+	walletData *WalletData,
+	recipients []Recipient,
+	feeRate FeeRate,
+	coinControl CoinControl,
+) (
+	*psbt.Packet,
+	Selection,
+	error,
+) {
+	chainParams, err := walletData.Wallet.Network.ChainParams()
+	if err != nil {
+		return nil, Selection{}, err
+	}
+
+	utxos, err := walletData.selectableUTXOs(coinControl)
+	if err != nil {
+		return nil, Selection{}, err
+	}
+
+	return walletData.Wallet.createUnsignedTx(
+		recipients,
+		utxos,
+		feeRate,
+		chainParams,
+		DustLimit,
+		coinControl,
+	)
+}
+
+func (w Wallet) CreateUnsignedTx(
+	recipients []Recipient,
+	utxos scanwallet.UtxoCollection,
+	feeRate FeeRate,
+	chainParams *chaincfg.Params,
+	minChangeAmount uint64,
+) (
+	*psbt.Packet,
+	error,
+) {
+	packet, _, err := w.createUnsignedTx(recipients, utxos, feeRate, chainParams, minChangeAmount, CoinControl{})
+	return packet, err
+}
+
+func (w Wallet) createUnsignedTx(
+	recipients []Recipient,
+	utxos scanwallet.UtxoCollection,
+	feeRate FeeRate,
+	chainParams *chaincfg.Params,
+	minChangeAmount uint64,
+	coinControl CoinControl,
+) (
+	*psbt.Packet,
+	Selection,
+	error,
+) {
+	selection, recipients, err := w.selectCoins(recipients, utxos, feeRate, chainParams, minChangeAmount, coinControl)
+	if err != nil {
+		return nil, Selection{}, err
+	}
+
+	packet, err := w.unsignedPsbt(recipients, selection.Inputs, chainParams)
+	if err != nil {
+		return nil, Selection{}, err
+	}
+	return packet, selection, nil
+}
+
+// CreateUnsignedSweep is the watch-only counterpart to SweepTo. It returns an unsigned PSBT spending all coins
+// allowed by the coin control to a single address with the fee subtracted and no change output.
+func CreateUnsignedSweep(
+	walletData *WalletData,
+	address string,
+	feeRate FeeRate,
+	coinControl CoinControl,
+) (
+	*psbt.Packet,
+	Selection,
+	error,
+) {
+	chainParams, err := walletData.Wallet.Network.ChainParams()
+	if err != nil {
+		return nil, Selection{}, err
+	}
+
+	utxos, err := walletData.selectableUTXOs(coinControl)
+	if err != nil {
+		return nil, Selection{}, err
+	}
+
+	selection, recipient, err := sweepSelection(utxos, address, feeRate, chainParams)
+	if err != nil {
+		return nil, Selection{}, err
+	}
+
+	packet, err := walletData.Wallet.unsignedPsbt([]Recipient{recipient}, utxos, chainParams)
+	if err != nil {
+		return nil, Selection{}, err
+	}
+	return packet, selection, nil
+}
+
+// unsignedPsbt builds the PSBT spending the selected coins to the final recipients, including the change.
+// Every input carries its witness utxo and its BIP352 tweak.
+func (w Wallet) unsignedPsbt(
+	recipients []Recipient,
+	selectedUTXOs []*UTXO,
+	chainParams *chaincfg.Params,
+) (
+	*psbt.Packet,
+	error,
+) {
+	// the vins only carry the tweaks, never the full secret keys
+	vins := make([]*bip352.Vin, len(selectedUTXOs))
+	for i, utxo := range selectedUTXOs {
+		vin := ConvertOwnedUTXOIntoVin(utxo)
+		vins[i] = &vin
+	}
+
+	recipients, err := w.parseRecipientsWatchOnly(recipients, vins, chainParams)
+	if err != nil {
+		return nil, err
+	}
+
+	err = sanityCheckRecipientsForSending(recipients)
+	if err != nil {
+		return nil, err
+	}
+
+	packet, err := CreateUnsignedPsbt(recipients, vins)
+	if err != nil {
+		return nil, err
+	}
+
+	vinMap := make(map[wire.OutPoint]*bip352.Vin, len(vins))
+	for _, vin := range vins {
+		hash, err := chainhash.NewHash(bip352.ReverseBytesCopy(vin.Txid[:]))
+		if err != nil {
+			return nil, err
+		}
+		vinMap[*wire.NewOutPoint(hash, vin.Vout)] = vin
+	}
+
+	packet.Inputs = make([]psbt.PInput, len(packet.UnsignedTx.TxIn))
+	for i, txIn := range packet.UnsignedTx.TxIn {
+		vin, ok := vinMap[txIn.PreviousOutPoint]
+		if !ok {
+			return nil, fmt.Errorf("no vin found for input %s", txIn.PreviousOutPoint)
+		}
+		packet.Inputs[i] = psbt.PInput{
+			WitnessUtxo: wire.NewTxOut(int64(vin.Amount), vin.ScriptPubKey),
+			SighashType: txscript.SigHashDefault,
+			Unknowns: []*psbt.Unknown{{
+				Key:   psbtTweakKey,
+				Value: bytes.Clone(vin.SecretKey[:]),
+			}},
+		}
+	}
+	packet.Outputs = make([]psbt.POutput, len(packet.UnsignedTx.TxOut))
+
+	return packet, nil
+}
+
+// SignUnsignedTx signs a PSBT created by a watch-only wallet with the spend secret and returns the final transaction.
+// The keys come from the BIP352 tweaks in the inputs, the wallet doesn't need to know the coins.
+// Every tweak must produce the output key of its witness utxo, otherwise nothing is signed.
+func (w Wallet) SignUnsignedTx(packet *psbt.Packet) (*wire.MsgTx, error) {
+	if w.IsWatchOnly() {
+		return nil, ErrWatchOnly
+	}
+	if len(packet.Inputs) != len(packet.UnsignedTx.TxIn) {
+		return nil, fmt.Errorf("psbt has %d inputs but the transaction %d", len(packet.Inputs), len(packet.UnsignedTx.TxIn))
+	}
+
+	vins := make([]*bip352.Vin, len(packet.Inputs))
+	for i, input := range packet.Inputs {
+		outpoint := packet.UnsignedTx.TxIn[i].PreviousOutPoint
+		if input.WitnessUtxo == nil {
+			return nil, fmt.Errorf("input %s has no witness utxo", outpoint)
+		}
+
+		var tweak []byte
+		for _, unknown := range input.Unknowns {
+			if bytes.Equal(unknown.Key, psbtTweakKey) {
+				tweak = unknown.Value
+			}
+		}
+		if len(tweak) != 32 {
+			return nil, fmt.Errorf("input %s has no silent payment tweak", outpoint)
+		}
+
+		pubKey, err := w.OutputPubKey([32]byte(tweak))
+		if err != nil {
+			return nil, err
+		}
+		script := input.WitnessUtxo.PkScript
+		if !txscript.IsPayToTaproot(script) || !bytes.Equal(script[2:], pubKey.SerializeCompressed()[1:]) {
+			return nil, fmt.Errorf("input %s is not owned by this wallet", outpoint)
+		}
+
+		secretKey := bip352.AddPrivateKeys([32]byte(tweak), [32]byte(w.SpendSecret))
+		vins[i] = &bip352.Vin{
+			Txid:         [32]byte(bip352.ReverseBytesCopy(outpoint.Hash[:])),
+			Vout:         outpoint.Index,
+			Amount:       uint64(input.WitnessUtxo.Value),
+			ScriptPubKey: script,
+			SecretKey:    &secretKey,
+			Taproot:      true,
+		}
+	}
+
+	if err := SignPsbt(packet, vins); err != nil {
+		return nil, fmt.Errorf("failed to sign psbt: %w", err)
+	}
+	if err := psbt.MaybeFinalizeAll(packet); err != nil {
+		return nil, fmt.Errorf("failed to finalize psbt: %w", err)
+	}
+
+	finalTx, err := psbt.Extract(packet)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract transaction: %w", err)
+	}
+	return finalTx, nil
+}
+
+// parseRecipientsWatchOnly is the watch-only counterpart to ParseRecipients.
+// Outputs to our own silent payment addresses are derived like a receiver would: b_scan * input_hash * A_sum.
+func (w Wallet) parseRecipientsWatchOnly(
+	recipients []Recipient,
+	vins []*bip352.Vin,
+	chainParams *chaincfg.Params,
+) (
+	[]Recipient,
+	error,
+) {
+	mainnet := chainParams.Name == chaincfg.MainNetParams.Name
+	scanPub := w.PubKeyScan()
+
+	var regular, own []Recipient
+	for _, recipient := range recipients {
+		if len(recipient.GetPkScript()) > 0 || !bip352.IsSilentPaymentAddress(recipient.GetAddress()) {
+			regular = append(regular, recipient)
+			continue
+		}
+		recipientScan, _, err := bip352.DecodeSilentPaymentAddressToKeys(recipient.GetAddress(), mainnet)
+		if err != nil {
+			return nil, err
+		}
+		if recipientScan != scanPub {
+			return nil, ErrWatchOnlyForeignSP
+		}
+		own = append(own, recipient)
+	}
+
+	parsed, err := ParseRecipients(regular, vins, chainParams)
+	if err != nil {
+		return nil, err
+	}
+	if len(own) == 0 {
+		return parsed, nil
+	}
+
+	sharedSecret, err := w.receiverSharedSecret(vins)
+	if err != nil {
+		return nil, err
+	}
+
+	// k is counted per scan key in recipient order, same as bip352.SenderCreateOutputs
+	for k, recipient := range own {
+		_, spendPub, err := bip352.DecodeSilentPaymentAddressToKeys(recipient.GetAddress(), mainnet)
+		if err != nil {
+			return nil, err
+		}
+		output, err := bip352.CreateOutputPubKey(sharedSecret, spendPub, uint32(k))
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, &RecipientImpl{
+			Address:  recipient.GetAddress(),
+			PkScript: append([]byte{0x51, 0x20}, output[:]...),
+			Amount:   recipient.GetAmount(),
+		})
+	}
+
+	return parsed, nil
+}
+
+// receiverSharedSecret computes the BIP352 shared secret of taproot inputs like a receiver: b_scan * input_hash * A_sum
+func (w Wallet) receiverSharedSecret(vins []*bip352.Vin) ([33]byte, error) {
+	// taproot inputs always count with their even y public key
+	var inputPubKeys [][33]byte
+	for _, vin := range vins {
+		inputPubKeys = append(inputPubKeys, bip352.ConvertToFixedLength33(append([]byte{0x02}, vin.ScriptPubKey[2:]...)))
+	}
+	publicKeySum := inputPubKeys[0]
+	for _, pubKey := range inputPubKeys[1:] {
+		var err error
+		publicKeySum, err = bip352.AddPublicKeys(publicKeySum, pubKey)
+		if err != nil {
+			return [33]byte{}, err
+		}
+	}
+
+	inputHash, err := bip352.ComputeInputHash(vins, publicKeySum)
+	if err != nil {
+		return [33]byte{}, err
+	}
+
+	return bip352.CreateSharedSecret(publicKeySum, [32]byte(w.ScanSecret), &inputHash)
+}
+
+// MaxFeeRate is the highest fee rate InspectUnsignedTx accepts, anything above is taken for a mistake
+var MaxFeeRate = SatPerVByte(1000)
+
+// ErrAbsurdFee is returned by InspectUnsignedTx for a PSBT paying more than MaxFeeRate
+var ErrAbsurdFee = fmt.Errorf("the fee rate is above %s", MaxFeeRate)
+
+// PsbtOutput is an output of an unsigned transaction as shown before signing
+type PsbtOutput struct {
+	Address string
+	Amount  uint64
+	// Change is set for outputs to this wallet's change address
+	Change bool
+}
+
+// InspectUnsignedTx returns the outputs, the fee and the fee rate on the estimated signed size of a PSBT,
+// so they can be checked before signing. It fails if the outputs exceed the inputs or the fee rate is above MaxFeeRate.
+func (w Wallet) InspectUnsignedTx(packet *psbt.Packet) ([]PsbtOutput, uint64, FeeRate, error) {
+	chainParams, err := w.Network.ChainParams()
+	if err != nil {
+		return nil, 0, FeeRate{}, err
+	}
+	if len(packet.Inputs) != len(packet.UnsignedTx.TxIn) {
+		return nil, 0, FeeRate{}, fmt.Errorf("psbt has %d inputs but the transaction %d", len(packet.Inputs), len(packet.UnsignedTx.TxIn))
+	}
+
+	var sumInputs uint64
+	allTaproot := true
+	vins := make([]*bip352.Vin, len(packet.Inputs))
+	for i, input := range packet.Inputs {
+		outpoint := packet.UnsignedTx.TxIn[i].PreviousOutPoint
+		if input.WitnessUtxo == nil {
+			return nil, 0, FeeRate{}, fmt.Errorf("input %s has no witness utxo", outpoint)
+		}
+		sumInputs += uint64(input.WitnessUtxo.Value)
+		allTaproot = allTaproot && txscript.IsPayToTaproot(input.WitnessUtxo.PkScript)
+		vins[i] = &bip352.Vin{
+			Txid:         [32]byte(bip352.ReverseBytesCopy(outpoint.Hash[:])),
+			Vout:         outpoint.Index,
+			Amount:       uint64(input.WitnessUtxo.Value),
+			ScriptPubKey: input.WitnessUtxo.PkScript,
+			Taproot:      true,
+		}
+	}
+
+	// the change outputs are derived like a receiver would, k counts over all our outputs
+	var changeKeys [][32]byte
+	if allTaproot && len(vins) > 0 {
+		sharedSecret, err := w.receiverSharedSecret(vins)
+		if err != nil {
+			return nil, 0, FeeRate{}, err
+		}
+		_, changeSpend, err := bip352.DecodeSilentPaymentAddressToKeys(w.ChangeAddress(), w.Network == NetworkMainnet)
+		if err != nil {
+			return nil, 0, FeeRate{}, err
+		}
+		for k := range packet.UnsignedTx.TxOut {
+			key, err := bip352.CreateOutputPubKey(sharedSecret, changeSpend, uint32(k))
+			if err != nil {
+				return nil, 0, FeeRate{}, err
+			}
+			changeKeys = append(changeKeys, key)
+		}
+	}
+
+	var sumOutputs uint64
+	var outputLens []int
+	outputs := make([]PsbtOutput, len(packet.UnsignedTx.TxOut))
+	for i, out := range packet.UnsignedTx.TxOut {
+		if out.Value < 0 {
+			return nil, 0, FeeRate{}, fmt.Errorf("output %d has a negative amount", i)
+		}
+		sumOutputs += uint64(out.Value)
+		outputLens = append(outputLens, len(out.PkScript))
+
+		outputs[i] = PsbtOutput{Address: fmt.Sprintf("script %x", out.PkScript), Amount: uint64(out.Value)}
+		_, addresses, _, err := txscript.ExtractPkScriptAddrs(out.PkScript, chainParams)
+		if err == nil && len(addresses) == 1 {
+			outputs[i].Address = addresses[0].EncodeAddress()
+		}
+		if txscript.IsPayToTaproot(out.PkScript) {
+			for _, key := range changeKeys {
+				if bytes.Equal(out.PkScript[2:], key[:]) {
+					outputs[i].Change = true
+				}
+			}
+		}
+	}
+
+	if sumOutputs > sumInputs {
+		return nil, 0, FeeRate{}, fmt.Errorf("outputs (%d sats) exceed inputs (%d sats)", sumOutputs, sumInputs)
+	}
+	fee := sumInputs - sumOutputs
+	feeRate := FeeRateOf(fee, EstimateVSize(len(packet.Inputs), outputLens))
+	if feeRate.SatPerKvB() > MaxFeeRate.SatPerKvB() {
+		return nil, 0, FeeRate{}, fmt.Errorf("%w: %d sats is %s", ErrAbsurdFee, fee, feeRate)
+	}
+
+	return outputs, fee, feeRate, nil
+}
@@ -0,0 +1,210 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	scanwallet "github.com/setavenger/blindbit-scan/pkg/wallet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testWatchOnlyPair(t *testing.T) (*Wallet, *Wallet) {
+	full, err := Import(testMnemonic, "", NetworkSignet)
+	require.NoError(t, err)
+
+	watchOnly, err := NewWatchOnly(*full.GetScanOnlyParams())
+	require.NoError(t, err)
+	return full, watchOnly
+}
+
+// testOwnedUTXOs creates coins owned by w with deterministic tweaks
+func testOwnedUTXOs(t *testing.T, w *Wallet, amounts ...uint64) scanwallet.UtxoCollection {
+	var utxos scanwallet.UtxoCollection
+	for i, amount := range amounts {
+		tweak := sha256.Sum256([]byte{byte(i)})
+		pubKey, err := w.OutputPubKey(tweak)
+		require.NoError(t, err)
+
+		utxo := &UTXO{
+			Txid:         sha256.Sum256([]byte{byte(i), 0xff}),
+			Vout:         uint32(i),
+			Amount:       amount,
+			PrivKeyTweak: tweak,
+			State:        scanwallet.StateUnspent,
+		}
+		copy(utxo.PubKey[:], pubKey.SerializeCompressed()[1:])
+		utxos = append(utxos, utxo)
+	}
+	return utxos
+}
+
+func TestWatchOnly_OutputPubKeyMatchesFullWallet(t *testing.T) {
+	full, watchOnly := testWatchOnlyPair(t)
+	assert.True(t, watchOnly.IsWatchOnly())
+	assert.Equal(t, full.PubKeySpend(), watchOnly.PubKeySpend())
+	assert.Equal(t, full.ChangeAddress(), watchOnly.ChangeAddress())
+
+	tweak := sha256.Sum256([]byte("tweak"))
+	fromSecret, err := full.OutputPubKey(tweak)
+	require.NoError(t, err)
+	fromPublic, err := watchOnly.OutputPubKey(tweak)
+	require.NoError(t, err)
+	assert.Equal(t, fromSecret.SerializeCompressed(), fromPublic.SerializeCompressed())
+}
+
+func TestWatchOnly_UnsignedTxMatchesSignedTx(t *testing.T) {
+	full, watchOnly := testWatchOnlyPair(t)
+	utxos := testOwnedUTXOs(t, full, 50_000, 80_000)
+
+	destination, err := btcutil.NewAddressTaproot(utxos[0].PubKey[:], &chaincfg.SigNetParams)
+	require.NoError(t, err)
+	recipients := func() []Recipient {
+		return []Recipient{&RecipientImpl{Address: destination.String(), Amount: 100_000}}
+	}
+
+	txBytes, err := full.SendToRecipients(recipients(), utxos, SatPerVByte(2), &chaincfg.SigNetParams, DustLimit, false)
+	require.NoError(t, err)
+	var signed wire.MsgTx
+	require.NoError(t, signed.Deserialize(bytes.NewReader(txBytes)))
+
+	packet, err := watchOnly.CreateUnsignedTx(recipients(), utxos, SatPerVByte(2), &chaincfg.SigNetParams, DustLimit)
+	require.NoError(t, err)
+
+	// same inputs and the change output to our own SP address must be identical
+	require.Len(t, signed.TxOut, 2)
+	assert.Equal(t, signed.TxOut, packet.UnsignedTx.TxOut)
+	assert.Equal(t, signed.TxIn[0].PreviousOutPoint, packet.UnsignedTx.TxIn[0].PreviousOutPoint)
+	for _, in := range packet.Inputs {
+		require.NotNil(t, in.WitnessUtxo)
+		require.Len(t, in.Unknowns, 1)
+	}
+
+	_, err = packet.B64Encode()
+	assert.NoError(t, err)
+}
+
+func TestWatchOnly_RefusesToSign(t *testing.T) {
+	full, watchOnly := testWatchOnlyPair(t)
+	utxos := testOwnedUTXOs(t, full, 50_000)
+
+	_, err := watchOnly.SendToRecipients(
+		[]Recipient{&RecipientImpl{Address: full.ChangeAddress(), Amount: 10_000}},
+		utxos, SatPerVByte(2), &chaincfg.SigNetParams, DustLimit, false,
+	)
+	assert.ErrorIs(t, err, ErrWatchOnly)
+
+	_, err = watchOnly.Sweep(utxos, full.ChangeAddress(), SatPerVByte(2), &chaincfg.SigNetParams)
+	assert.ErrorIs(t, err, ErrWatchOnly)
+
+	other, err := New("", NetworkSignet)
+	require.NoError(t, err)
+	_, err = watchOnly.CreateUnsignedTx(
+		[]Recipient{&RecipientImpl{Address: other.ChangeAddress(), Amount: 10_000}},
+		utxos, SatPerVByte(2), &chaincfg.SigNetParams, DustLimit,
+	)
+	assert.ErrorIs(t, err, ErrWatchOnlyForeignSP)
+}
+
+func TestWatchOnly_SignUnsignedTxRoundTrip(t *testing.T) {
+	full, watchOnly := testWatchOnlyPair(t)
+	utxos := testOwnedUTXOs(t, full, 50_000, 80_000)
+
+	destination, err := btcutil.NewAddressTaproot(make([]byte, 32), &chaincfg.SigNetParams)
+	require.NoError(t, err)
+	recipients := []Recipient{&RecipientImpl{Address: destination.String(), Amount: 100_000}}
+
+	packet, err := watchOnly.CreateUnsignedTx(recipients, utxos, SatPerVByte(2), &chaincfg.SigNetParams, DustLimit)
+	require.NoError(t, err)
+	encoded, err := packet.B64Encode()
+	require.NoError(t, err)
+
+	decode := func() *psbt.Packet {
+		decoded, err := psbt.NewFromRawBytes(strings.NewReader(encoded), true)
+		require.NoError(t, err)
+		return decoded
+	}
+
+	_, err = watchOnly.SignUnsignedTx(decode())
+	assert.ErrorIs(t, err, ErrWatchOnly)
+
+	tx, err := full.SignUnsignedTx(decode())
+	require.NoError(t, err)
+	assert.Equal(t, packet.UnsignedTx.TxHash(), tx.TxHash())
+
+	// every input spends its witness utxo with a valid signature
+	prevOuts := txscript.NewMultiPrevOutFetcher(nil)
+	for i, input := range decode().Inputs {
+		prevOuts.AddPrevOut(tx.TxIn[i].PreviousOutPoint, input.WitnessUtxo)
+	}
+	sigHashes := txscript.NewTxSigHashes(tx, prevOuts)
+	for i, txIn := range tx.TxIn {
+		prevOut := prevOuts.FetchPrevOutput(txIn.PreviousOutPoint)
+		engine, err := txscript.NewEngine(prevOut.PkScript, tx, i, txscript.StandardVerifyFlags, nil, sigHashes, prevOut.Value, prevOuts)
+		require.NoError(t, err)
+		assert.NoError(t, engine.Execute(), "input %d", i)
+	}
+
+	// a tweak that doesn't match the witness utxo is refused
+	other := testOwnedUTXOs(t, full, 1, 2, 3)[2]
+	tampered := decode()
+	tampered.Inputs[0].WitnessUtxo.PkScript = append([]byte{0x51, 0x20}, other.PubKey[:]...)
+	_, err = full.SignUnsignedTx(tampered)
+	assert.ErrorContains(t, err, "not owned by this wallet")
+}
+
+func TestWatchOnly_InspectUnsignedTx(t *testing.T) {
+	full, watchOnly := testWatchOnlyPair(t)
+	utxos := testOwnedUTXOs(t, full, 50_000, 80_000)
+
+	destination, err := btcutil.NewAddressTaproot(make([]byte, 32), &chaincfg.SigNetParams)
+	require.NoError(t, err)
+	recipients := []Recipient{&RecipientImpl{Address: destination.String(), Amount: 100_000}}
+
+	packet, err := watchOnly.CreateUnsignedTx(recipients, utxos, SatPerVByte(2), &chaincfg.SigNetParams, DustLimit)
+	require.NoError(t, err)
+	encoded, err := packet.B64Encode()
+	require.NoError(t, err)
+	decode := func() *psbt.Packet {
+		decoded, err := psbt.NewFromRawBytes(strings.NewReader(encoded), true)
+		require.NoError(t, err)
+		return decoded
+	}
+
+	outputs, fee, feeRate, err := full.InspectUnsignedTx(decode())
+	require.NoError(t, err)
+	require.Len(t, outputs, 2)
+
+	var sumOutputs uint64
+	var change []PsbtOutput
+	for _, out := range outputs {
+		sumOutputs += out.Amount
+		if out.Change {
+			change = append(change, out)
+		} else {
+			assert.Equal(t, PsbtOutput{Address: destination.String(), Amount: 100_000}, out)
+		}
+	}
+	require.Len(t, change, 1)
+	assert.Equal(t, 130_000-sumOutputs, fee)
+	assert.Equal(t, SatPerVByte(2).SatPerKvB()/1000, feeRate.SatPerKvB()/1000)
+
+	// outputs above the inputs would underflow the fee
+	tampered := decode()
+	tampered.UnsignedTx.TxOut[0].Value += 200_000
+	_, _, _, err = full.InspectUnsignedTx(tampered)
+	assert.ErrorContains(t, err, "exceed inputs")
+
+	// an input worth far more than the outputs leaves it all to the fee
+	tampered = decode()
+	tampered.Inputs[0].WitnessUtxo.Value += 1_000_000
+	_, _, _, err = full.InspectUnsignedTx(tampered)
+	assert.ErrorIs(t, err, ErrAbsurdFee)
+}